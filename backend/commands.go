@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+	"olivia-conciliation/backend/service"
+	"olivia-conciliation/backend/sheets"
+	"olivia-conciliation/backend/sqlstore"
+)
+
+// runCommand runs a one-shot subcommand (olivia-backend <name>) instead of the server.
+func runCommand(ctx context.Context, name string, cfg config.Config) error {
+	switch name {
+	case "bootstrap":
+		return bootstrap(ctx, cfg)
+	case "migrate-sqlite":
+		return migrateSQLite(ctx, cfg)
+	}
+	return fmt.Errorf("unknown command %q (available: bootstrap, migrate-sqlite)", name)
+}
+
+// bootstrap creates the tabs, headers, native tables and DIF formula the backend expects,
+// leaving whatever already exists alone. Differences from the layout are reported and make
+// the command fail, so they are not missed in a setup script.
+func bootstrap(ctx context.Context, cfg config.Config) error {
+	missing := collectMissingEnvVars([]string{"SHEET_SPREADSHEET_ID", "SHEET_ES", "SHEET_DIF", "SHEET_REJ", "SHEET_HOM"})
+	if len(missing) > 0 {
+		return fmt.Errorf("missing or empty required env vars: %s", strings.Join(missing, ", "))
+	}
+
+	client, err := sheets.NewClient(ctx, cfg.SpreadsheetID, retryPolicy(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to create sheets client: %w", err)
+	}
+
+	layout := []sheets.TabLayout{
+		{Name: cfg.SheetHOM, Header: transactionHeader(cfg, cfg.SheetHOM), Columns: headerColumns(cfg, cfg.SheetHOM)},
+		{Name: cfg.SheetES, Header: transactionHeader(cfg, cfg.SheetES), Columns: headerColumns(cfg, cfg.SheetES), Table: true},
+		{Name: cfg.SheetREJ, Header: transactionHeader(cfg, cfg.SheetREJ), Columns: headerColumns(cfg, cfg.SheetREJ), Table: true},
+		{Name: cfg.SheetDIF, FormulaFor: func(cols map[string]models.ColumnMap) string {
+			return sheets.DIFFormula(cfg.SheetHOM, cfg.SheetES, cfg.SheetREJ, cols)
+		}},
+	}
+	if cfg.SheetAUD != "" {
+		header := make([]string, len(service.AuditHeader))
+		for i, h := range service.AuditHeader {
+			header[i] = fmt.Sprintf("%v", h)
+		}
+		layout = append(layout, sheets.TabLayout{Name: cfg.SheetAUD, Header: header, Table: true})
+	}
+
+	report, err := client.Bootstrap(ctx, layout)
+	if err != nil {
+		return err
+	}
+	for _, c := range report.Created {
+		log.Printf("created %s", c)
+	}
+	for _, d := range report.Drift {
+		log.Printf("drift: %s", d)
+	}
+	if len(report.Drift) > 0 {
+		return fmt.Errorf("spreadsheet differs from the expected layout in %d places; fix them by hand", len(report.Drift))
+	}
+	if len(report.Created) == 0 {
+		log.Printf("spreadsheet already matches the expected layout")
+	}
+	return nil
+}
+
+// transactionHeader is the header bootstrap writes to a transaction tab: the default
+// layout, with the header names configured for that tab.
+func transactionHeader(cfg config.Config, tab string) []string {
+	names := cfg.HeadersFor(tab)
+	header := append([]string(nil), models.TransactionHeader...)
+	header[models.ColumnData] = names.Data
+	header[models.ColumnDescricao] = names.Descricao
+	header[models.ColumnValor] = names.Valor
+	header[models.ColumnCategoria] = names.Categoria
+	header[models.ColumnDono] = names.Dono
+	header[models.ColumnBanco] = names.Banco
+	header[models.ColumnConta] = names.Conta
+	header[models.ColumnRecorrente] = names.Recorrente
+	header[models.ColumnIdParcela] = names.IdParcela
+	return header
+}
+
+// headerColumns finds a transaction tab's columns by header name, as the service does, so
+// bootstrap accepts any column order the service can read.
+func headerColumns(cfg config.Config, tab string) func([]interface{}) (models.ColumnMap, error) {
+	return func(header []interface{}) (models.ColumnMap, error) {
+		return service.Parser{}.Columns(cfg.HeadersFor(tab), header)
+	}
+}
+
+// migrateSQLite imports ES, HOM, REJ and, if configured, AUD from the spreadsheet into the
+// SQLite database at SQLITE_PATH, replacing whatever it held. DIF is not copied: the
+// database computes it from the other tables.
+func migrateSQLite(ctx context.Context, cfg config.Config) error {
+	missing := collectMissingEnvVars([]string{"SHEET_SPREADSHEET_ID", "SQLITE_PATH", "SHEET_ES", "SHEET_DIF", "SHEET_REJ", "SHEET_HOM"})
+	if len(missing) > 0 {
+		return fmt.Errorf("missing or empty required env vars: %s", strings.Join(missing, ", "))
+	}
+
+	client, err := sheets.NewClient(ctx, cfg.SpreadsheetID, retryPolicy(cfg))
+	if err != nil {
+		return fmt.Errorf("failed to create sheets client: %w", err)
+	}
+	client.UseFormattedValues(cfg.SheetsFormattedValues)
+
+	tabs := []string{cfg.SheetES, cfg.SheetHOM, cfg.SheetREJ}
+	if cfg.SheetAUD != "" {
+		tabs = append(tabs, cfg.SheetAUD)
+	}
+	data := make(map[string][][]interface{}, len(tabs))
+	for _, tab := range tabs {
+		rows, err := client.FetchRows(ctx, tab)
+		if err != nil {
+			return fmt.Errorf("failed to read %q: %w", tab, err)
+		}
+		if tab != cfg.SheetAUD {
+			if rows, err = toDefaultLayout(cfg, tab, rows); err != nil {
+				return err
+			}
+		}
+		data[tab] = rows
+	}
+
+	store, err := sqlstore.Open(ctx, cfg.SQLitePath, sqliteTabs(cfg))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if err := store.Replace(ctx, data); err != nil {
+		return fmt.Errorf("failed to import into %s: %w", cfg.SQLitePath, err)
+	}
+
+	for _, tab := range tabs {
+		n := len(data[tab]) - 1
+		if n < 0 {
+			n = 0
+		}
+		log.Printf("imported %d rows from %q", n, tab)
+	}
+	return nil
+}
+
+// toDefaultLayout moves the columns of a transaction tab, found by header, to the default
+// positions the SQLite store keeps (A:J), under the header bootstrap would write. Columns
+// that are not transaction fields are dropped, as the store would drop them anyway.
+func toDefaultLayout(cfg config.Config, tab string, rows [][]interface{}) ([][]interface{}, error) {
+	var header []interface{}
+	if len(rows) > 0 {
+		header = rows[0]
+	}
+	cols, err := service.Parser{}.Columns(cfg.HeadersFor(tab), header)
+	if err != nil {
+		return nil, fmt.Errorf("tab %q: %w", tab, err)
+	}
+	src, dst := cols.Fields(), models.DefaultColumns.Fields()
+	// The Id column (A) is not a transaction field; it is kept when found by its header.
+	idCol := slices.IndexFunc(header, func(v interface{}) bool {
+		return strings.EqualFold(strings.TrimSpace(models.CellText(v)), models.TransactionHeader[0])
+	})
+
+	names := transactionHeader(cfg, tab)
+	out := make([][]interface{}, 0, len(rows))
+	out = append(out, make([]interface{}, len(names)))
+	for i, name := range names {
+		out[0][i] = name
+	}
+	for _, row := range rows[1:] {
+		moved := make([]interface{}, len(names))
+		for i := range moved {
+			moved[i] = ""
+		}
+		if idCol >= 0 && idCol < len(row) {
+			moved[0] = row[idCol]
+		}
+		for i := range src {
+			if src[i] < len(row) {
+				moved[dst[i]] = row[src[i]]
+			}
+		}
+		out = append(out, moved)
+	}
+	return out, nil
+}
+
+func sqliteTabs(cfg config.Config) sqlstore.Tabs {
+	return sqlstore.Tabs{ES: cfg.SheetES, DIF: cfg.SheetDIF, REJ: cfg.SheetREJ, HOM: cfg.SheetHOM, AUD: cfg.SheetAUD}
+}
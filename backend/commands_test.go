@@ -0,0 +1,38 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+	"olivia-conciliation/backend/service"
+)
+
+func TestToDefaultLayout_MovesColumnsByHeader(t *testing.T) {
+	cfg := config.Config{TabColumnHeaders: map[string]config.ColumnHeaders{"REJ": {IdParcela: "Parcela"}}}
+	rows := [][]interface{}{
+		{"Parcela", "Obs", "Id", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente"},
+		{"p-1", "nota", "7", "05/03/2026", "LOJA", "-10,00", "Casa", "Fulano", "Nubank", "Cartão", "Não"},
+	}
+
+	got, err := toDefaultLayout(cfg, "REJ", rows)
+	if err != nil {
+		t.Fatalf("toDefaultLayout() error: %v", err)
+	}
+	if got[0][models.ColumnIdParcela] != "Parcela" || got[0][models.ColumnData] != "Data" {
+		t.Errorf("expected the configured names at the default positions, got %v", got[0])
+	}
+	row := got[1]
+	if len(row) != len(models.TransactionHeader) || row[0] != "7" || row[models.ColumnIdParcela] != "p-1" ||
+		row[models.ColumnValor] != "-10,00" || row[models.ColumnRecorrente] != "Não" {
+		t.Errorf("unexpected row in the default layout: %v", row)
+	}
+}
+
+func TestToDefaultLayout_RefusesATabWithoutTheHeaders(t *testing.T) {
+	_, err := toDefaultLayout(config.Config{}, "ES", [][]interface{}{{"A", "B"}})
+	if !errors.Is(err, service.ErrMissingHeader) {
+		t.Errorf("expected ErrMissingHeader, got %v", err)
+	}
+}
@@ -1,37 +1,280 @@
 package config
 
 import (
+	"encoding/json"
+	"log"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 )
 
+// DefaultMatchTolerance é a tolerância absoluta (em R$) usada quando nada é configurado.
+// Corresponde ao critério histórico de Candidata: diferença de Valor inferior a R$ 5,00.
+const DefaultMatchTolerance = 5.00
+
+// DefaultAutoConciliationMinScore é a pontuação mínima para o Aceitar automático.
+const DefaultAutoConciliationMinScore = 0.8
+
+// DefaultMatchDateWindowDays é a janela padrão (± dias) entre a Data da DIF e a da Candidata.
+const DefaultMatchDateWindowDays = 31
+
+// DefaultMatchMaxGroupSize limita quantas linhas entram numa combinação de split/merge.
+const DefaultMatchMaxGroupSize = 3
+
+// DefaultCacheTTL é por quanto tempo uma aba lida fica em cache. Curto de propósito:
+// escritas feitas fora do backend só aparecem quando ele vence.
+const DefaultCacheTTL = 10 * time.Second
+
+// DefaultRequestTimeout é o prazo padrão de uma requisição autenticada da API, somadas
+// todas as chamadas ao Sheets que ela fizer.
+const DefaultRequestTimeout = 60 * time.Second
+
+// Backends de armazenamento aceitos em STORAGE_BACKEND.
+const (
+	BackendSheets = "sheets" // a planilha do Google (padrão)
+	BackendLocal  = "local"  // arquivos CSV/JSON em LocalDataDir, um por aba
+	BackendSQLite = "sqlite" // banco SQLite em SQLitePath
+)
+
+// ToleranceRule define quanto o Valor de uma Candidata pode divergir do Valor da DIF.
+// Absolute é em reais; Percent é percentual sobre o Valor da DIF. Vale o maior dos dois.
+// Dono, Banco e Conta vazios funcionam como curinga.
+type ToleranceRule struct {
+	Dono     string  `json:"dono,omitempty"`
+	Banco    string  `json:"banco,omitempty"`
+	Conta    string  `json:"conta,omitempty"`
+	Absolute float64 `json:"absolute"`
+	Percent  float64 `json:"percent"`
+}
+
+// ColumnHeaders são os textos de cabeçalho que identificam as colunas de uma transação
+// numa aba. A comparação ignora maiúsculas e espaços nas pontas; campos vazios caem nos de
+// DefaultColumnHeaders.
+type ColumnHeaders struct {
+	Data       string `json:"data,omitempty"`
+	Descricao  string `json:"descricao,omitempty"`
+	Valor      string `json:"valor,omitempty"`
+	Categoria  string `json:"categoria,omitempty"`
+	Dono       string `json:"dono,omitempty"`
+	Banco      string `json:"banco,omitempty"`
+	Conta      string `json:"conta,omitempty"`
+	Recorrente string `json:"recorrente,omitempty"`
+	IdParcela  string `json:"idParcela,omitempty"`
+}
+
+// DefaultColumnHeaders é o cabeçalho que o bootstrap escreve (models.TransactionHeader).
+var DefaultColumnHeaders = ColumnHeaders{
+	Data:       "Data",
+	Descricao:  "Descrição",
+	Valor:      "Valor",
+	Categoria:  "Categoria",
+	Dono:       "Dono",
+	Banco:      "Banco",
+	Conta:      "Conta",
+	Recorrente: "Recorrente",
+	IdParcela:  "IdParcela",
+}
+
+// orDefaults completa os campos vazios de h com os de d.
+func (h ColumnHeaders) orDefaults(d ColumnHeaders) ColumnHeaders {
+	pick := func(v, fallback string) string {
+		if strings.TrimSpace(v) == "" {
+			return fallback
+		}
+		return v
+	}
+	return ColumnHeaders{
+		Data:       pick(h.Data, d.Data),
+		Descricao:  pick(h.Descricao, d.Descricao),
+		Valor:      pick(h.Valor, d.Valor),
+		Categoria:  pick(h.Categoria, d.Categoria),
+		Dono:       pick(h.Dono, d.Dono),
+		Banco:      pick(h.Banco, d.Banco),
+		Conta:      pick(h.Conta, d.Conta),
+		Recorrente: pick(h.Recorrente, d.Recorrente),
+		IdParcela:  pick(h.IdParcela, d.IdParcela),
+	}
+}
+
 // Config holds all configuration read from environment variables at startup.
 type Config struct {
+	// StorageBackend escolhe onde as abas são lidas e escritas: BackendSheets, BackendLocal
+	// ou BackendSQLite.
+	StorageBackend string
+	// LocalDataDir é o diretório com um arquivo por aba, usado por BackendLocal.
+	LocalDataDir string
+	// SQLitePath é o arquivo do banco usado por BackendSQLite e pelo migrate-sqlite.
+	SQLitePath    string
 	SpreadsheetID string
 	SheetES       string
 	SheetDIF      string
 	SheetREJ      string
 	SheetHOM      string
+	SheetAUD      string // opcional: aba da trilha de auditoria
 	AdminUser     string
 	AdminPass     string
 	JWTSecret     string
 	AppOrigin     string
 	CookieDomain  string
 	CookieSecure  bool
+
+	// MatchTolerance é a regra padrão; ToleranceRules a sobrescrevem por Dono/Banco/Conta.
+	MatchTolerance ToleranceRule
+	ToleranceRules []ToleranceRule
+
+	// MatchDateWindowDays é a janela (± dias) entre as Datas da DIF e da Candidata.
+	// 0 desliga a restrição.
+	MatchDateWindowDays int
+
+	// MatchMaxGroupSize é o máximo de linhas somadas numa Candidata agrupada (split/merge).
+	MatchMaxGroupSize int
+
+	// AutoConciliationMinScore é a pontuação mínima (0..1) da Candidata única para que a
+	// conciliação automática a aceite sem revisão manual.
+	AutoConciliationMinScore float64
+
+	// Retentativas das chamadas à API do Sheets (429/5xx) e cota compartilhada por minuto.
+	// Zeros caem nos padrões de sheets.DefaultRetryPolicy.
+	SheetsMaxAttempts       int
+	SheetsRetryBaseDelay    time.Duration
+	SheetsRetryMaxDelay     time.Duration
+	SheetsRequestsPerMinute int
+	// SheetsAttemptTimeout é o prazo de cada tentativa de chamada ao Sheets.
+	SheetsAttemptTimeout time.Duration
+
+	// RequestTimeout é o prazo de cada requisição da API; vencido, as chamadas ao Sheets
+	// em andamento são canceladas e o cliente recebe 504. 0 desliga.
+	RequestTimeout time.Duration
+
+	// CacheTTL é por quanto tempo as abas lidas ficam em cache. 0 desliga o cache.
+	CacheTTL time.Duration
+
+	// StrictParsing recusa linhas com células ilegíveis (Valor, Data, Recorrente, Dono) em
+	// vez de lê-las com zeros: ficam fora das listagens e as ações sobre elas falham.
+	StrictParsing bool
+
+	// SheetsFormattedValues lê as células do Sheets como a planilha as exibe, no lugar dos
+	// valores tipados (Valor como número, Data como número de série). Só para planilhas
+	// em que a leitura tipada não sirva; o Parser aceita os dois.
+	SheetsFormattedValues bool
+
+	// ColumnHeaders diz por qual cabeçalho achar cada coluna nas abas de transações;
+	// TabColumnHeaders o sobrescreve por aba, para abas com cabeçalhos próprios.
+	ColumnHeaders    ColumnHeaders
+	TabColumnHeaders map[string]ColumnHeaders
+}
+
+// HeadersFor devolve os cabeçalhos esperados na aba tab: os dela, completados pelos
+// globais e, por fim, pelos padrões.
+func (c Config) HeadersFor(tab string) ColumnHeaders {
+	return c.TabColumnHeaders[tab].orDefaults(c.ColumnHeaders.orDefaults(DefaultColumnHeaders))
 }
 
 func FromEnv() Config {
 	return Config{
-		SpreadsheetID: os.Getenv("SHEET_SPREADSHEET_ID"),
-		SheetES:       os.Getenv("SHEET_ES"),
-		SheetDIF:      os.Getenv("SHEET_DIF"),
-		SheetREJ:      os.Getenv("SHEET_REJ"),
-		SheetHOM:      os.Getenv("SHEET_HOM"),
-		AdminUser:     os.Getenv("ADMIN_USER"),
-		AdminPass:     os.Getenv("ADMIN_PASS"),
-		JWTSecret:     os.Getenv("JWT_SECRET"),
-		AppOrigin:     os.Getenv("APP_ORIGIN"),
-		CookieDomain:  os.Getenv("COOKIE_DOMAIN"),
-		CookieSecure:  strings.ToLower(strings.TrimSpace(os.Getenv("COOKIE_SECURE"))) != "false",
+		StorageBackend: storageBackendFromEnv("STORAGE_BACKEND"),
+		LocalDataDir:   strings.TrimSpace(os.Getenv("LOCAL_DATA_DIR")),
+		SQLitePath:     strings.TrimSpace(os.Getenv("SQLITE_PATH")),
+		SpreadsheetID:  os.Getenv("SHEET_SPREADSHEET_ID"),
+		SheetES:        os.Getenv("SHEET_ES"),
+		SheetDIF:       os.Getenv("SHEET_DIF"),
+		SheetREJ:       os.Getenv("SHEET_REJ"),
+		SheetHOM:       os.Getenv("SHEET_HOM"),
+		SheetAUD:       os.Getenv("SHEET_AUD"),
+		AdminUser:      os.Getenv("ADMIN_USER"),
+		AdminPass:      os.Getenv("ADMIN_PASS"),
+		JWTSecret:      os.Getenv("JWT_SECRET"),
+		AppOrigin:      os.Getenv("APP_ORIGIN"),
+		CookieDomain:   os.Getenv("COOKIE_DOMAIN"),
+		CookieSecure:   strings.ToLower(strings.TrimSpace(os.Getenv("COOKIE_SECURE"))) != "false",
+		MatchTolerance: ToleranceRule{
+			Absolute: floatFromEnv("MATCH_TOLERANCE_ABS", DefaultMatchTolerance),
+			Percent:  floatFromEnv("MATCH_TOLERANCE_PCT", 0),
+		},
+		ToleranceRules:           toleranceRulesFromEnv("MATCH_TOLERANCE_RULES"),
+		MatchDateWindowDays:      intFromEnv("MATCH_DATE_WINDOW_DAYS", DefaultMatchDateWindowDays),
+		MatchMaxGroupSize:        intFromEnv("MATCH_MAX_GROUP_SIZE", DefaultMatchMaxGroupSize),
+		AutoConciliationMinScore: floatFromEnv("AUTO_CONCILIATION_MIN_SCORE", DefaultAutoConciliationMinScore),
+		SheetsMaxAttempts:        intFromEnv("SHEETS_MAX_ATTEMPTS", 0),
+		SheetsRetryBaseDelay:     time.Duration(intFromEnv("SHEETS_RETRY_BASE_MS", 0)) * time.Millisecond,
+		SheetsRetryMaxDelay:      time.Duration(intFromEnv("SHEETS_RETRY_MAX_MS", 0)) * time.Millisecond,
+		SheetsRequestsPerMinute:  intFromEnv("SHEETS_REQUESTS_PER_MINUTE", 0),
+		SheetsAttemptTimeout:     time.Duration(intFromEnv("SHEETS_ATTEMPT_TIMEOUT_MS", 0)) * time.Millisecond,
+		RequestTimeout:           time.Duration(intFromEnv("REQUEST_TIMEOUT_MS", int(DefaultRequestTimeout/time.Millisecond))) * time.Millisecond,
+		CacheTTL:                 time.Duration(intFromEnv("CACHE_TTL_MS", int(DefaultCacheTTL/time.Millisecond))) * time.Millisecond,
+		StrictParsing:            strings.ToLower(strings.TrimSpace(os.Getenv("STRICT_PARSING"))) == "true",
+		SheetsFormattedValues:    strings.ToLower(strings.TrimSpace(os.Getenv("SHEETS_FORMATTED_VALUES"))) == "true",
+		ColumnHeaders:            jsonFromEnv("COLUMN_HEADERS", ColumnHeaders{}),
+		TabColumnHeaders:         jsonFromEnv[map[string]ColumnHeaders]("COLUMN_HEADERS_BY_TAB", nil),
+	}
+}
+
+// floatFromEnv lê um número da variável name, aceitando vírgula decimal.
+// Valores ausentes ou inválidos caem no fallback, com aviso no log para os inválidos.
+func floatFromEnv(name string, fallback float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(strings.ReplaceAll(raw, ",", "."), 64)
+	if err != nil || f < 0 {
+		log.Printf("warning: invalid %s=%q, using %v", name, raw, fallback)
+		return fallback
+	}
+	return f
+}
+
+// intFromEnv lê um inteiro não-negativo da variável name, com o mesmo fallback de floatFromEnv.
+func intFromEnv(name string, fallback int) int {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		log.Printf("warning: invalid %s=%q, using %v", name, raw, fallback)
+		return fallback
+	}
+	return n
+}
+
+// storageBackendFromEnv lê o backend de armazenamento; vazio vale BackendSheets.
+// Um valor desconhecido é devolvido como veio, para o main recusá-lo na subida.
+func storageBackendFromEnv(name string) string {
+	raw := strings.ToLower(strings.TrimSpace(os.Getenv(name)))
+	if raw == "" {
+		return BackendSheets
+	}
+	return raw
+}
+
+// toleranceRulesFromEnv lê as regras por Dono/Banco/Conta de um array JSON, no mesmo
+// estilo do BANKS_JSON. Ex.: [{"banco":"Nubank","absolute":1,"percent":2}].
+func toleranceRulesFromEnv(name string) []ToleranceRule {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return nil
+	}
+	var rules []ToleranceRule
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		log.Printf("warning: invalid %s, ignoring tolerance overrides: %v", name, err)
+		return nil
+	}
+	return rules
+}
+
+// jsonFromEnv lê um JSON da variável name, no mesmo estilo de MATCH_TOLERANCE_RULES.
+// Ex.: COLUMN_HEADERS={"valor":"Montante"}. Ausente ou inválido vale fallback.
+func jsonFromEnv[T any](name string, fallback T) T {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return fallback
+	}
+	var v T
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		log.Printf("warning: invalid %s, ignoring it: %v", name, err)
+		return fallback
 	}
+	return v
 }
@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestFromEnv_CookieSecure_DefaultTrue(t *testing.T) {
 	t.Setenv("COOKIE_SECURE", "")
@@ -53,3 +56,110 @@ func TestFromEnv_ReadsSheetNames(t *testing.T) {
 		t.Errorf("SheetHOM=%q", cfg.SheetHOM)
 	}
 }
+
+func TestFromEnv_MatchTolerance_Defaults(t *testing.T) {
+	t.Setenv("MATCH_TOLERANCE_ABS", "")
+	t.Setenv("MATCH_TOLERANCE_PCT", "")
+	t.Setenv("MATCH_TOLERANCE_RULES", "")
+	cfg := FromEnv()
+	if cfg.MatchTolerance.Absolute != DefaultMatchTolerance || cfg.MatchTolerance.Percent != 0 {
+		t.Errorf("MatchTolerance=%+v", cfg.MatchTolerance)
+	}
+	if len(cfg.ToleranceRules) != 0 {
+		t.Errorf("ToleranceRules=%+v", cfg.ToleranceRules)
+	}
+}
+
+func TestFromEnv_MatchTolerance_ReadsOverrides(t *testing.T) {
+	t.Setenv("MATCH_TOLERANCE_ABS", "2,50")
+	t.Setenv("MATCH_TOLERANCE_PCT", "1.5")
+	t.Setenv("MATCH_TOLERANCE_RULES", `[{"dono":"Alice","banco":"Nubank","absolute":1,"percent":3}]`)
+	cfg := FromEnv()
+	if cfg.MatchTolerance.Absolute != 2.5 || cfg.MatchTolerance.Percent != 1.5 {
+		t.Errorf("MatchTolerance=%+v", cfg.MatchTolerance)
+	}
+	want := ToleranceRule{Dono: "Alice", Banco: "Nubank", Absolute: 1, Percent: 3}
+	if len(cfg.ToleranceRules) != 1 || cfg.ToleranceRules[0] != want {
+		t.Errorf("ToleranceRules=%+v", cfg.ToleranceRules)
+	}
+}
+
+func TestFromEnv_MatchTolerance_InvalidFallsBack(t *testing.T) {
+	t.Setenv("MATCH_TOLERANCE_ABS", "cinco")
+	t.Setenv("MATCH_TOLERANCE_RULES", `{not json`)
+	cfg := FromEnv()
+	if cfg.MatchTolerance.Absolute != DefaultMatchTolerance {
+		t.Errorf("expected fallback to default, got %v", cfg.MatchTolerance.Absolute)
+	}
+	if cfg.ToleranceRules != nil {
+		t.Errorf("expected no rules for invalid JSON, got %+v", cfg.ToleranceRules)
+	}
+}
+
+func TestFromEnv_MatchDateWindowDays(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want int
+	}{
+		{"", DefaultMatchDateWindowDays},
+		{"10", 10},
+		{"0", 0},
+		{"-3", DefaultMatchDateWindowDays},
+		{"dez", DefaultMatchDateWindowDays},
+	}
+	for _, c := range cases {
+		t.Setenv("MATCH_DATE_WINDOW_DAYS", c.raw)
+		if got := FromEnv().MatchDateWindowDays; got != c.want {
+			t.Errorf("MATCH_DATE_WINDOW_DAYS=%q: got %d, want %d", c.raw, got, c.want)
+		}
+	}
+}
+
+func TestFromEnv_SheetsRetry(t *testing.T) {
+	t.Setenv("SHEETS_MAX_ATTEMPTS", "7")
+	t.Setenv("SHEETS_RETRY_BASE_MS", "250")
+	t.Setenv("SHEETS_RETRY_MAX_MS", "")
+	t.Setenv("SHEETS_REQUESTS_PER_MINUTE", "abc")
+
+	cfg := FromEnv()
+	if cfg.SheetsMaxAttempts != 7 || cfg.SheetsRetryBaseDelay != 250*time.Millisecond {
+		t.Errorf("unexpected retry config: %+v", cfg)
+	}
+	if cfg.SheetsRetryMaxDelay != 0 || cfg.SheetsRequestsPerMinute != 0 {
+		t.Errorf("unset/invalid values should stay zero (client defaults apply), got %+v", cfg)
+	}
+}
+
+func TestFromEnv_StorageBackend(t *testing.T) {
+	t.Setenv("STORAGE_BACKEND", "")
+	if got := FromEnv().StorageBackend; got != BackendSheets {
+		t.Errorf("expected %q when unset, got %q", BackendSheets, got)
+	}
+	t.Setenv("STORAGE_BACKEND", " Local ")
+	t.Setenv("LOCAL_DATA_DIR", " ./data ")
+	cfg := FromEnv()
+	if cfg.StorageBackend != BackendLocal || cfg.LocalDataDir != "./data" {
+		t.Errorf("expected local backend on ./data, got %q on %q", cfg.StorageBackend, cfg.LocalDataDir)
+	}
+}
+
+func TestFromEnv_ColumnHeaders_PerTabOverGlobalOverDefault(t *testing.T) {
+	t.Setenv("COLUMN_HEADERS", `{"valor":"Montante"}`)
+	t.Setenv("COLUMN_HEADERS_BY_TAB", `{"HOM":{"idParcela":"Id da Parcela","valor":"Valor (R$)"}}`)
+	cfg := FromEnv()
+
+	es := cfg.HeadersFor("ES")
+	if es.Valor != "Montante" || es.IdParcela != "IdParcela" {
+		t.Errorf("ES headers: %+v", es)
+	}
+	hom := cfg.HeadersFor("HOM")
+	if hom.Valor != "Valor (R$)" || hom.IdParcela != "Id da Parcela" || hom.Data != "Data" {
+		t.Errorf("HOM headers: %+v", hom)
+	}
+}
+
+func TestHeadersFor_ZeroConfigUsesDefaults(t *testing.T) {
+	if got := (Config{}).HeadersFor("ES"); got != DefaultColumnHeaders {
+		t.Errorf("expected the default headers, got %+v", got)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+	"olivia-conciliation/backend/sheets"
+	"olivia-conciliation/backend/sqlstore"
+)
+
+// difFixture exercises every case of the DIF rule: an IdParcela in ES, one in REJ, one
+// padded with spaces, one in neither, and a row without IdParcela.
+var difFixture = map[string][][]interface{}{
+	"HOM": {
+		headerRow(),
+		txRow("p-es"), txRow("p-rej"), txRow(" p-pad "), txRow("p-pending"), txRow(""),
+	},
+	"ES":  {headerRow(), txRow("p-es"), txRow("p-pad")},
+	"REJ": {headerRow(), txRow("p-rej")},
+}
+
+// wantDIF is the same for every backend: only the row with an IdParcela in neither tab.
+var wantDIF = []string{"p-pending"}
+
+func headerRow() []interface{} {
+	out := make([]interface{}, len(models.TransactionHeader))
+	for i, name := range models.TransactionHeader {
+		out[i] = name
+	}
+	return out
+}
+
+func txRow(idParcela string) []interface{} {
+	return []interface{}{"1", "05/03/2026", "LOJA", "-10,00", "", "Fulano", "Nubank", "Cartão", "Não", idParcela}
+}
+
+func difConfig(t *testing.T, backend string) config.Config {
+	dir := t.TempDir()
+	return config.Config{
+		StorageBackend: backend,
+		LocalDataDir:   dir,
+		SQLitePath:     filepath.Join(dir, "olivia.db"),
+		SheetES:        "ES", SheetDIF: "DIF", SheetREJ: "REJ", SheetHOM: "HOM",
+	}
+}
+
+func difIds(t *testing.T, rows [][]interface{}) []string {
+	t.Helper()
+	var ids []string
+	for _, row := range rows[1:] {
+		ids = append(ids, strings.TrimSpace(models.CellText(row[models.ColumnIdParcela])))
+	}
+	return ids
+}
+
+func TestDIF_SameRuleOnEveryBackend(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("local", func(t *testing.T) {
+		cfg := difConfig(t, config.BackendLocal)
+		for tab, rows := range difFixture {
+			writeCSV(t, filepath.Join(cfg.LocalDataDir, tab+".csv"), rows)
+		}
+		repo, err := newRepository(cfg)
+		if err != nil {
+			t.Fatalf("newRepository() error: %v", err)
+		}
+		rows, err := repo.FetchRows(ctx, "DIF")
+		if err != nil {
+			t.Fatalf("FetchRows(DIF) error: %v", err)
+		}
+		if got := difIds(t, rows); !slices.Equal(got, wantDIF) {
+			t.Errorf("DIF = %q, want %q", got, wantDIF)
+		}
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		cfg := difConfig(t, config.BackendSQLite)
+		repo, err := newRepository(cfg)
+		if err != nil {
+			t.Fatalf("newRepository() error: %v", err)
+		}
+		store := repo.(*sqlstore.Store)
+		t.Cleanup(func() { store.Close() })
+		if err := store.Replace(ctx, difFixture); err != nil {
+			t.Fatalf("Replace() error: %v", err)
+		}
+		rows, err := store.FetchRows(ctx, "DIF")
+		if err != nil {
+			t.Fatalf("FetchRows(DIF) error: %v", err)
+		}
+		if got := difIds(t, rows); !slices.Equal(got, wantDIF) {
+			t.Errorf("DIF = %q, want %q", got, wantDIF)
+		}
+	})
+
+	// The spreadsheet evaluates the formula itself; what can be checked here is that it
+	// states the same rule: a non-empty IdParcela, matched against ES and REJ.
+	t.Run("sheets", func(t *testing.T) {
+		formula := sheets.DIFFormula("HOM", "ES", "REJ", nil)
+		for _, part := range []string{`('HOM'!J1:J<>"")`, `ISNA(MATCH('HOM'!J1:J, {'ES'!J2:J; 'REJ'!J2:J}, 0))`} {
+			if !strings.Contains(formula, part) {
+				t.Errorf("expected %s in the DIF formula, got %s", part, formula)
+			}
+		}
+	})
+}
+
+func writeCSV(t *testing.T, path string, rows [][]interface{}) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create() error: %v", err)
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = models.CellText(v)
+		}
+		if err := w.Write(record); err != nil {
+			t.Fatalf("Write() error: %v", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		t.Fatalf("Flush() error: %v", err)
+	}
+}
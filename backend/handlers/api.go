@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,14 +24,46 @@ func NewHandler(svc *service.Logic, cfg config.Config) *Handler {
 	return &Handler{svc: svc, cfg: cfg}
 }
 
+// TimeoutMiddleware bounds each request with cfg.RequestTimeout. Handlers pass
+// r.Context() down to the Sheets calls, so they are cancelled when it expires, and also
+// when the browser gives up on the request.
+func (h *Handler) TimeoutMiddleware(next http.Handler) http.Handler {
+	if h.cfg.RequestTimeout <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), h.cfg.RequestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// serverError reports an unexpected service error: 504 when the request deadline ran
+// out while talking to Sheets, 500 otherwise.
+func serverError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		http.Error(w, err.Error(), http.StatusGatewayTimeout)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
 // extractPathID parses the integer segment at position depth from the end of path.
 // depth=1 → last segment, depth=2 → second-to-last, etc.
+// Row 0 is the header, so only positive IDs are accepted.
 func extractPathID(path string, depth int) (int, error) {
 	parts := strings.Split(path, "/")
 	if len(parts) < depth+1 {
 		return 0, errors.New("invalid path")
 	}
-	return strconv.Atoi(parts[len(parts)-depth])
+	id, err := strconv.Atoi(parts[len(parts)-depth])
+	if err != nil {
+		return 0, err
+	}
+	if id < 1 {
+		return 0, errors.New("invalid id")
+	}
+	return id, nil
 }
 
 func (h *Handler) GetConciliations(w http.ResponseWriter, r *http.Request) {
@@ -37,9 +72,9 @@ func (h *Handler) GetConciliations(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	summary, err := h.svc.GetConciliations()
+	summary, err := h.svc.GetConciliations(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -58,9 +93,13 @@ func (h *Handler) GetConciliationDetails(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	details, err := h.svc.GetConciliationDetails(id)
+	details, err := h.svc.GetConciliationDetails(r.Context(), id)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		if errors.Is(err, service.ErrUnparseableRow) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		serverError(w, err)
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -85,8 +124,24 @@ func (h *Handler) AcceptConciliation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.svc.Accept(id, req.EsRowIndices); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.svc.Accept(r.Context(), id, req); err != nil {
+		var invalid *service.AcceptValidationError
+		switch {
+		case errors.As(err, &invalid):
+			status := http.StatusUnprocessableEntity
+			if errors.Is(err, service.ErrEsRowNotPending) || errors.Is(err, service.ErrStaleRow) {
+				status = http.StatusConflict
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(models.AcceptErrorResponse{Error: err.Error(), Rows: invalid.Rows})
+		case errors.Is(err, service.ErrStaleRow):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrEmptySelection), errors.Is(err, service.ErrUnparseableRow):
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			serverError(w, err)
+		}
 		return
 	}
 
@@ -94,6 +149,73 @@ func (h *Handler) AcceptConciliation(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "accepted"})
 }
 
+func (h *Handler) GetAssignment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	result, err := h.svc.GetAssignment(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) AutoConciliate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Corpo vazio é dry-run: gravar exige pedir explicitamente {"commit": true}.
+	var req models.AutoConciliationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.svc.AutoConciliate(r.Context(), req.Commit)
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *Handler) UnlinkConciliation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.UnlinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.svc.Unlink(r.Context(), req.IdParcela)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdParcelaNotInES):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrEmptyIdParcela):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
 func (h *Handler) RejectConciliation(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -106,8 +228,14 @@ func (h *Handler) RejectConciliation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.svc.Reject(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	req, err := decodeRowAction(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.Reject(r.Context(), id, req.IdParcela); err != nil {
+		writeRowActionError(w, err)
 		return
 	}
 
@@ -115,15 +243,100 @@ func (h *Handler) RejectConciliation(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]string{"status": "rejected"})
 }
 
+func (h *Handler) RestoreRejected(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.RestoreRejected(r.Context(), req.IdParcela); err != nil {
+		switch {
+		case errors.Is(err, service.ErrIdParcelaNotInREJ):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, service.ErrEmptyIdParcela):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			serverError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]string{"status": "restored"})
+}
+
+func (h *Handler) GetCacheStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.svc.CacheStats())
+}
+
+// GetRowDiagnostics lists the ES, DIF, HOM and REJ rows with cells the strict parser
+// rejects, so they can be fixed in the spreadsheet.
+func (h *Handler) GetRowDiagnostics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	rows, err := h.svc.RowDiagnostics(r.Context())
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rows)
+}
+
+// Healthz is the liveness probe: it answers as long as the process is serving requests.
+func (h *Handler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz is the readiness probe: 200 when every check passed, 503 otherwise, with the
+// result of each check in the body either way.
+func (h *Handler) Readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The route is public: the details (Sheets or storage errors) only go to the log.
+	report := h.svc.Readiness(r.Context())
+	status := models.ReadinessStatus{Ready: report.Ready, Failing: []string{}}
+	for _, c := range report.Checks {
+		if !c.OK {
+			status.Failing = append(status.Failing, c.Name)
+			log.Printf("readyz: %s failed: %s", c.Name, c.Detail)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(status)
+}
+
 func (h *Handler) ListNonRecurringDif(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	items, err := h.svc.ListNonRecurringDIF()
+	items, err := h.svc.ListNonRecurringDIF(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 
@@ -143,8 +356,14 @@ func (h *Handler) MoveNonRecurringDifToES(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := h.svc.MoveNonRecurringDifToES(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	req, err := decodeRowAction(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.MoveNonRecurringDifToES(r.Context(), id, req.IdParcela); err != nil {
+		writeRowActionError(w, err)
 		return
 	}
 
@@ -164,8 +383,14 @@ func (h *Handler) MoveNonRecurringDifToREJ(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	if err := h.svc.MoveNonRecurringDifToREJ(id); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	req, err := decodeRowAction(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.svc.MoveNonRecurringDifToREJ(r.Context(), id, req.IdParcela); err != nil {
+		writeRowActionError(w, err)
 		return
 	}
 
@@ -179,9 +404,9 @@ func (h *Handler) MoveAllNonRecurringDifToES(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	result, err := h.svc.MoveAllNonRecurringDifToES()
+	result, err := h.svc.MoveAllNonRecurringDifToES(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, err)
 		return
 	}
 
@@ -195,10 +420,10 @@ func writeUpdateError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, service.ErrTransactionNotInHOM):
 		http.Error(w, err.Error(), http.StatusNotFound)
-	case errors.Is(err, service.ErrEmptyIdParcela):
+	case errors.Is(err, service.ErrEmptyIdParcela), errors.Is(err, service.ErrInvalidDate):
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	default:
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serverError(w, err)
 	}
 }
 
@@ -214,7 +439,7 @@ func (h *Handler) UpdateNonRecurringDifCategory(w http.ResponseWriter, r *http.R
 		return
 	}
 
-	if err := h.svc.UpdateDifCategory(req.IdParcela, req.Categoria); err != nil {
+	if err := h.svc.UpdateDifCategory(r.Context(), req.IdParcela, req.Categoria); err != nil {
 		writeUpdateError(w, err)
 		return
 	}
@@ -235,7 +460,7 @@ func (h *Handler) UpdateNonRecurringDifDate(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.svc.UpdateDifDate(req.IdParcela, req.Data); err != nil {
+	if err := h.svc.UpdateDifDate(r.Context(), req.IdParcela, req.Data); err != nil {
 		writeUpdateError(w, err)
 		return
 	}
@@ -243,3 +468,24 @@ func (h *Handler) UpdateNonRecurringDifDate(w http.ResponseWriter, r *http.Reque
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{"status": "date_updated"})
 }
+
+// decodeRowAction lê o corpo opcional das ações por índice da DIF. Corpo vazio é aceito
+// por compatibilidade e desliga a checagem de concorrência.
+func decodeRowAction(r *http.Request) (models.RowActionRequest, error) {
+	var req models.RowActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		return req, err
+	}
+	return req, nil
+}
+
+func writeRowActionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrStaleRow):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrUnparseableRow):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		serverError(w, err)
+	}
+}
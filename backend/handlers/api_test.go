@@ -1,11 +1,13 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"olivia-conciliation/backend/config"
 	"olivia-conciliation/backend/models"
@@ -16,38 +18,70 @@ import (
 type fakeRepo struct {
 	sheets   map[string][][]interface{}
 	appended map[string][][]interface{}
-	written  []struct{ sheet string; row, col int; value string }
+	deleted  map[string][]int
+	written  []struct{ sheet string; row, col int; value interface{} }
 }
 
 func newFakeRepo(sheets map[string][][]interface{}) *fakeRepo {
 	return &fakeRepo{
 		sheets:   sheets,
 		appended: make(map[string][][]interface{}),
+		deleted:  make(map[string][]int),
 	}
 }
 
-func (f *fakeRepo) FetchRows(sheet string) ([][]interface{}, error) {
+func (f *fakeRepo) FetchRows(ctx context.Context, sheet string) ([][]interface{}, error) {
 	return f.sheets[sheet], nil
 }
-func (f *fakeRepo) WriteCell(sheet string, row, col int, value string) error {
+func (f *fakeRepo) FetchSheets(ctx context.Context, sheets ...string) (map[string][][]interface{}, error) {
+	out := make(map[string][][]interface{}, len(sheets))
+	for _, s := range sheets {
+		rows, err := f.FetchRows(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		out[s] = rows
+	}
+	return out, nil
+}
+func (f *fakeRepo) WriteCell(ctx context.Context, sheet string, row, col int, value interface{}) error {
 	f.written = append(f.written, struct {
 		sheet    string
 		row, col int
-		value    string
+		value    interface{}
 	}{sheet, row, col, value})
 	return nil
 }
-func (f *fakeRepo) AppendRow(sheet string, values []interface{}) error {
+func (f *fakeRepo) WriteCells(ctx context.Context, sheet string, cells []models.CellUpdate) error {
+	for _, c := range cells {
+		if err := f.WriteCell(ctx, sheet, c.Row, c.Col, c.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (f *fakeRepo) AppendRow(ctx context.Context, sheet string, values []interface{}) error {
 	f.appended[sheet] = append(f.appended[sheet], values)
 	return nil
 }
+func (f *fakeRepo) AppendRows(ctx context.Context, sheet string, rows [][]interface{}) error {
+	f.appended[sheet] = append(f.appended[sheet], rows...)
+	return nil
+}
+func (f *fakeRepo) DeleteRow(ctx context.Context, sheet string, row int) error {
+	return f.DeleteRows(ctx, sheet, []int{row})
+}
+func (f *fakeRepo) DeleteRows(ctx context.Context, sheet string, rows []int) error {
+	f.deleted[sheet] = append(f.deleted[sheet], rows...)
+	return nil
+}
 func newAPIHandler(repo *fakeRepo) *Handler {
 	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM"}
 	svc := service.NewLogic(repo, cfg)
 	return NewHandler(svc, cfg)
 }
 
-var apiHeader = []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+var apiHeader = []interface{}{"Id", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente", "IdParcela"}
 
 func apiRow(dono, banco, conta, valor, idParcela, recorrente string) []interface{} {
 	row := make([]interface{}, 10)
@@ -141,6 +175,69 @@ func TestAcceptConciliation_Returns200(t *testing.T) {
 	}
 }
 
+func TestAcceptConciliation_OutsideTolerance_Returns422(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "180.00", "", "sim")},
+	})
+	h := newAPIHandler(repo)
+	body := strings.NewReader(`{"esRowIndices":[1]}`)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/1/accept", body)
+	w := httptest.NewRecorder()
+
+	h.AcceptConciliation(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %d", len(repo.written))
+	}
+}
+
+func TestAcceptConciliation_AlreadyConciliated_Returns409WithRows(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-0", "sim")},
+	})
+	h := newAPIHandler(repo)
+	body := strings.NewReader(`{"esRowIndices":[1,7]}`)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/1/accept", body)
+	w := httptest.NewRecorder()
+
+	h.AcceptConciliation(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp models.AcceptErrorResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Rows) != 2 || resp.Rows[0].Reason != "not_pending" || resp.Rows[1].Reason != "not_found" {
+		t.Errorf("unexpected rows: %+v", resp.Rows)
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %d", len(repo.written))
+	}
+}
+
+func TestAcceptConciliation_EmptySelection_Returns422(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {apiHeader},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/1/accept", strings.NewReader(`{"esRowIndices":[]}`))
+	w := httptest.NewRecorder()
+
+	h.AcceptConciliation(w, r)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestAcceptConciliation_InvalidJSON(t *testing.T) {
 	h := newAPIHandler(newFakeRepo(nil))
 	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/1/accept", strings.NewReader("bad"))
@@ -161,6 +258,88 @@ func TestAcceptConciliation_InvalidPath(t *testing.T) {
 	}
 }
 
+func TestGetAssignment_Returns200(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodGet, "/api/conciliations/assignment", nil)
+	w := httptest.NewRecorder()
+
+	h.GetAssignment(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.ConciliationAssignment
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(result.Pairs) != 1 || result.Pairs[0].EsRowIndex != 1 {
+		t.Errorf("unexpected pairs: %+v", result.Pairs)
+	}
+}
+
+func TestAutoConciliate_EmptyBodyIsDryRun(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/auto", nil)
+	w := httptest.NewRecorder()
+
+	h.AutoConciliate(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result models.AutoConciliationResult
+	if err := json.NewDecoder(w.Body).Decode(&result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if !result.DryRun {
+		t.Error("expected DryRun=true for empty body")
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %+v", repo.written)
+	}
+}
+
+func TestUnlinkConciliation_Returns200(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"ES": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/unlink", strings.NewReader(`{"idParcela":"p-1"}`))
+	w := httptest.NewRecorder()
+
+	h.UnlinkConciliation(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.written) != 1 || repo.written[0].value != "" {
+		t.Errorf("unexpected WriteCell: %+v", repo.written)
+	}
+}
+
+func TestUnlinkConciliation_NotLinked_Returns404(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"ES": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/conciliations/unlink", strings.NewReader(`{"idParcela":"p-1"}`))
+	w := httptest.NewRecorder()
+
+	h.UnlinkConciliation(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestRejectConciliation_Returns200(t *testing.T) {
 	repo := newFakeRepo(map[string][][]interface{}{
 		"DIF": {apiHeader, apiRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
@@ -177,6 +356,37 @@ func TestRejectConciliation_Returns200(t *testing.T) {
 	}
 }
 
+func TestRestoreRejected_Returns200(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"REJ": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "p-9", "não")},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/rej/restore", strings.NewReader(`{"idParcela":"p-9"}`))
+	w := httptest.NewRecorder()
+
+	h.RestoreRejected(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.deleted["REJ"]) != 1 || repo.deleted["REJ"][0] != 1 {
+		t.Errorf("unexpected DeleteRow: %v", repo.deleted)
+	}
+}
+
+func TestRestoreRejected_NotInREJ_Returns404(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{"REJ": {apiHeader}})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/rej/restore", strings.NewReader(`{"idParcela":"p-9"}`))
+	w := httptest.NewRecorder()
+
+	h.RestoreRejected(w, r)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
 func TestListNonRecurringDif_Returns200(t *testing.T) {
 	repo := newFakeRepo(map[string][][]interface{}{
 		"DIF": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "", "não")},
@@ -215,6 +425,25 @@ func TestMoveNonRecurringDifToES_Returns200(t *testing.T) {
 	}
 }
 
+func TestMoveNonRecurringDifToES_StaleIdParcela_Returns409(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "p-2", "não")},
+		"ES":  {apiHeader},
+	})
+	h := newAPIHandler(repo)
+	r := httptest.NewRequest(http.MethodPost, "/api/dif/non-recurring/1/move-to-es", strings.NewReader(`{"idParcela":"p-1"}`))
+	w := httptest.NewRecorder()
+
+	h.MoveNonRecurringDifToES(w, r)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.appended["ES"]) != 0 {
+		t.Errorf("expected no AppendRow, got %d", len(repo.appended["ES"]))
+	}
+}
+
 func TestMoveNonRecurringDifToREJ_Returns200(t *testing.T) {
 	repo := newFakeRepo(map[string][][]interface{}{
 		"DIF": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "", "não")},
@@ -352,12 +581,33 @@ func TestUpdateNonRecurringDifDate_Returns200(t *testing.T) {
 	if w.Code != http.StatusOK {
 		t.Errorf("expected 200, got %d: %s", w.Code, w.Body.String())
 	}
-	if len(repo.written) != 1 || repo.written[0].sheet != "HOM" ||
-		repo.written[0].col != models.ColumnData || repo.written[0].value != "2026-06-15" {
+	if len(repo.written) != 1 || repo.written[0].sheet != "HOM" || repo.written[0].col != models.ColumnData {
+		t.Fatalf("unexpected WriteCell: %+v", repo.written)
+	}
+	if d, ok := repo.written[0].value.(models.Date); !ok || d.String() != "15/06/2026" {
 		t.Errorf("unexpected WriteCell: %+v", repo.written)
 	}
 }
 
+func TestUpdateNonRecurringDifDate_InvalidDate_Returns400(t *testing.T) {
+	repo := newFakeRepo(map[string][][]interface{}{
+		"HOM": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "parcela-7", "não")},
+	})
+	h := newAPIHandler(repo)
+	body := strings.NewReader(`{"idParcela":"parcela-7","data":"15 de junho"}`)
+	r := httptest.NewRequest(http.MethodPatch, "/api/dif/non-recurring/date", body)
+	w := httptest.NewRecorder()
+
+	h.UpdateNonRecurringDifDate(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no write, got %+v", repo.written)
+	}
+}
+
 func TestUpdateNonRecurringDifDate_NotInHOM_Returns404(t *testing.T) {
 	repo := newFakeRepo(map[string][][]interface{}{
 		"HOM": {apiHeader, apiRow("Bob", "BankX", "Poupanca", "200.00", "parcela-7", "não")},
@@ -447,6 +697,8 @@ func TestExtractPathID(t *testing.T) {
 		{"/api/dif/non-recurring/7/move-to-es", 2, 7, false},
 		{"/short", 2, 0, true},
 		{"/api/conciliations/abc", 1, 0, true},
+		{"/api/conciliations/0", 1, 0, true},
+		{"/api/conciliations/-1/accept", 2, 0, true},
 	}
 
 	for _, c := range cases {
@@ -466,3 +718,87 @@ func TestExtractPathID(t *testing.T) {
 		}
 	}
 }
+
+// deadlineRepo simula o Sheets que não responde antes do prazo da requisição.
+type deadlineRepo struct{ *fakeRepo }
+
+func (d deadlineRepo) FetchRows(ctx context.Context, sheet string) ([][]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (d deadlineRepo) FetchSheets(ctx context.Context, sheets ...string) (map[string][][]interface{}, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutMiddleware_Returns504WhenSheetsIsSlow(t *testing.T) {
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", RequestTimeout: 20 * time.Millisecond}
+	h := NewHandler(service.NewLogic(deadlineRepo{newFakeRepo(nil)}, cfg), cfg)
+	r := httptest.NewRequest(http.MethodGet, "/api/conciliations", nil)
+	w := httptest.NewRecorder()
+
+	h.TimeoutMiddleware(http.HandlerFunc(h.GetConciliations)).ServeHTTP(w, r)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyz_Returns200WhenHeadersMatch(t *testing.T) {
+	h := newAPIHandler(newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader}, "ES": {apiHeader}, "REJ": {apiHeader}, "HOM": {apiHeader},
+	}))
+	w := httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status models.ReadinessStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if !status.Ready || len(status.Failing) != 0 {
+		t.Errorf("expected no failing checks, got %+v", status)
+	}
+}
+
+func TestReadyz_Returns503WithFailingCheck(t *testing.T) {
+	letters := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	h := newAPIHandler(newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader}, "ES": {letters}, "REJ": {apiHeader}, "HOM": {apiHeader},
+	}))
+	w := httptest.NewRecorder()
+	h.Readyz(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+	var status models.ReadinessStatus
+	json.NewDecoder(w.Body).Decode(&status)
+	if status.Ready || len(status.Failing) != 1 || status.Failing[0] != "header:ES" {
+		t.Errorf("expected only header:ES failing, got %+v", status)
+	}
+	// The check details name the sheet's columns; they stay out of the public response.
+	if strings.Contains(w.Body.String(), "expected") || strings.Contains(w.Body.String(), "detail") {
+		t.Errorf("expected no check details in the response, got %s", w.Body.String())
+	}
+}
+
+func TestGetRowDiagnostics_Returns200(t *testing.T) {
+	bad := apiRow("Alice", "BancoBR", "Corrente", "n/a", "p-1", "sim")
+	bad[models.ColumnData] = "10/03/2026"
+	h := newAPIHandler(newFakeRepo(map[string][][]interface{}{
+		"DIF": {apiHeader, bad}, "ES": {apiHeader}, "REJ": {apiHeader}, "HOM": {apiHeader, bad},
+	}))
+	w := httptest.NewRecorder()
+	h.GetRowDiagnostics(w, httptest.NewRequest(http.MethodGet, "/api/diagnostics/rows", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var rows []models.RowDiagnostic
+	json.NewDecoder(w.Body).Decode(&rows)
+	if len(rows) != 2 || rows[0].Sheet != "DIF" || rows[1].Sheet != "HOM" || rows[0].Problems[0].Reason != "invalid_amount" {
+		t.Errorf("unexpected diagnostics: %+v", rows)
+	}
+}
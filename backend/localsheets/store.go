@@ -0,0 +1,390 @@
+// Package localsheets implements the service's SheetRepository over a local directory,
+// one file per tab, so the backend can run without Google credentials or network access.
+package localsheets
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"olivia-conciliation/backend/models"
+)
+
+// DIFView describes the tab the spreadsheet computes with a FILTER formula: the rows of
+// Source whose IdParcela is not present in any of the Exclude tabs.
+type DIFView struct {
+	Sheet   string
+	Source  string
+	Exclude []string
+	// IdParcela is the header of the IdParcela column per tab, which is found in each tab's
+	// header like the service does. Tabs not listed use the default "IdParcela".
+	IdParcela map[string]string
+}
+
+// idParcelaColumn finds the IdParcela column of tab in its header row.
+func (v DIFView) idParcelaColumn(tab string, rows [][]interface{}) (int, error) {
+	name := v.IdParcela[tab]
+	if name == "" {
+		name = models.TransactionHeader[models.ColumnIdParcela]
+	}
+	if len(rows) > 0 {
+		for i, cell := range rows[0] {
+			if strings.EqualFold(strings.TrimSpace(cellString(cell)), strings.TrimSpace(name)) {
+				return i, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("sheet %q has no %q column to compute %q from", tab, name, v.Sheet)
+}
+
+// Store keeps each tab in <dir>/<tab>.csv or <dir>/<tab>.json (an array of rows, each an
+// array of cells). Row 0 is the header, as in the spreadsheet. Cells are read back as
+// strings, like the formatted values the Sheets API returns.
+//
+// The DIF tab has no file: it is recomputed from HOM, ES and REJ on every read, which
+// stands in for the spreadsheet formula. Writing to it is an error.
+type Store struct {
+	dir string
+	dif DIFView
+
+	mu sync.Mutex
+}
+
+func NewStore(dir string, dif DIFView) (*Store, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open local data dir: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("local data dir %q is not a directory", dir)
+	}
+	return &Store{dir: dir, dif: dif}, nil
+}
+
+func (s *Store) FetchRows(ctx context.Context, sheetName string) ([][]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read(sheetName)
+}
+
+// FetchSheets reads every tab under the same lock, so the result is a consistent snapshot.
+func (s *Store) FetchSheets(ctx context.Context, sheetNames ...string) (map[string][][]interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make(map[string][][]interface{}, len(sheetNames))
+	for _, name := range sheetNames {
+		rows, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = rows
+	}
+	return out, nil
+}
+
+func (s *Store) WriteCell(ctx context.Context, sheetName string, rowIndex, colIndex int, value interface{}) error {
+	return s.WriteCells(ctx, sheetName, []models.CellUpdate{{Row: rowIndex, Col: colIndex, Value: models.CellText(value)}})
+}
+
+// WriteCells sets every cell and saves the tab once. Cells past the end of the tab grow it,
+// as writing below the data does in the spreadsheet.
+func (s *Store) WriteCells(ctx context.Context, sheetName string, cells []models.CellUpdate) error {
+	if len(cells) == 0 {
+		return nil
+	}
+	return s.update(ctx, sheetName, func(rows [][]interface{}) ([][]interface{}, error) {
+		for _, cell := range cells {
+			if cell.Row < 0 || cell.Col < 0 {
+				return nil, fmt.Errorf("invalid cell %d,%d in sheet %q", cell.Row, cell.Col, sheetName)
+			}
+			for len(rows) <= cell.Row {
+				rows = append(rows, nil)
+			}
+			for len(rows[cell.Row]) <= cell.Col {
+				rows[cell.Row] = append(rows[cell.Row], "")
+			}
+			rows[cell.Row][cell.Col] = cell.Value
+		}
+		return rows, nil
+	})
+}
+
+func (s *Store) AppendRow(ctx context.Context, sheetName string, values []interface{}) error {
+	return s.AppendRows(ctx, sheetName, [][]interface{}{values})
+}
+
+func (s *Store) AppendRows(ctx context.Context, sheetName string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.update(ctx, sheetName, func(existing [][]interface{}) ([][]interface{}, error) {
+		for _, values := range rows {
+			row := make([]interface{}, len(values))
+			for i, v := range values {
+				row[i] = cellString(v)
+			}
+			existing = append(existing, row)
+		}
+		return existing, nil
+	})
+}
+
+// DeleteRow removes row rowIndex (0-based, header included); the rows below shift up.
+func (s *Store) DeleteRow(ctx context.Context, sheetName string, rowIndex int) error {
+	return s.DeleteRows(ctx, sheetName, []int{rowIndex})
+}
+
+// DeleteRows removes every row in rowIndexes and saves the tab once.
+func (s *Store) DeleteRows(ctx context.Context, sheetName string, rowIndexes []int) error {
+	if len(rowIndexes) == 0 {
+		return nil
+	}
+	return s.update(ctx, sheetName, func(rows [][]interface{}) ([][]interface{}, error) {
+		drop := make(map[int]bool, len(rowIndexes))
+		for _, i := range rowIndexes {
+			if i < 1 || i >= len(rows) {
+				return nil, fmt.Errorf("row %d is not a data row of sheet %q", i, sheetName)
+			}
+			drop[i] = true
+		}
+		kept := make([][]interface{}, 0, len(rows))
+		for i, row := range rows {
+			if !drop[i] {
+				kept = append(kept, row)
+			}
+		}
+		return kept, nil
+	})
+}
+
+// update loads a tab, applies fn and saves the result. Nothing is saved if fn fails.
+func (s *Store) update(ctx context.Context, sheetName string, fn func([][]interface{}) ([][]interface{}, error)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if sheetName == s.dif.Sheet {
+		return fmt.Errorf("sheet %q is computed from %q and cannot be written", sheetName, s.dif.Source)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, err := s.path(sheetName)
+	if err != nil {
+		return err
+	}
+	rows, err := readFile(path)
+	if err != nil {
+		return err
+	}
+	rows, err = fn(rows)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, rows)
+}
+
+func (s *Store) read(sheetName string) ([][]interface{}, error) {
+	if sheetName == s.dif.Sheet {
+		return s.computeDIF()
+	}
+	path, err := s.path(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	return readFile(path)
+}
+
+// computeDIF reproduces the DIF formula: the HOM rows, header included, with an IdParcela
+// that appears in none of the Exclude tabs, in HOM order. Rows without IdParcela are left
+// out, as the formula leaves them.
+func (s *Store) computeDIF() ([][]interface{}, error) {
+	source, err := s.read(s.dif.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(source) < 2 {
+		return source, nil
+	}
+	sourceCol, err := s.dif.idParcelaColumn(s.dif.Source, source)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range s.dif.Exclude {
+		rows, err := s.read(name)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) < 2 {
+			continue
+		}
+		col, err := s.dif.idParcelaColumn(name, rows)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(rows); i++ {
+			if id := idParcela(rows[i], col); id != "" {
+				seen[id] = true
+			}
+		}
+	}
+
+	var out [][]interface{}
+	for i, row := range source {
+		if id := idParcela(row, sourceCol); i == 0 || (id != "" && !seen[id]) {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+// path finds the file of a tab, preferring .csv when both exist.
+func (s *Store) path(sheetName string) (string, error) {
+	if sheetName == "" || strings.ContainsAny(sheetName, `/\`) {
+		return "", fmt.Errorf("invalid sheet name %q", sheetName)
+	}
+	for _, ext := range []string{".csv", ".json"} {
+		p := filepath.Join(s.dir, sheetName+ext)
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("sheet %q not found in %s (expected %s.csv or %s.json)", sheetName, s.dir, sheetName, sheetName)
+}
+
+func readFile(path string) ([][]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %w", path, err)
+	}
+
+	var rows [][]interface{}
+	if filepath.Ext(path) == ".json" {
+		if len(bytes.TrimSpace(data)) > 0 {
+			if err := json.Unmarshal(data, &rows); err != nil {
+				return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+			}
+		}
+	} else {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+		}
+		for _, rec := range records {
+			row := make([]interface{}, len(rec))
+			for i, v := range rec {
+				row[i] = v
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	for i, row := range rows {
+		rows[i] = trimRow(row)
+	}
+	return trimRows(rows), nil
+}
+
+// writeFile saves the rows in the file's format through a temporary file, so a crash never
+// leaves a tab half written.
+func writeFile(path string, rows [][]interface{}) error {
+	var buf bytes.Buffer
+	if filepath.Ext(path) == ".json" {
+		data, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return fmt.Errorf("unable to encode %s: %w", path, err)
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	} else {
+		w := csv.NewWriter(&buf)
+		for _, row := range rows {
+			row = trimRow(row)
+			if len(row) == 0 {
+				// encoding/csv writes an empty record as a blank line, which it then skips on
+				// read; "" keeps the row so the indices below it stay put.
+				w.Flush()
+				buf.WriteString("\"\"\n")
+				continue
+			}
+			rec := make([]string, len(row))
+			for i, v := range row {
+				rec[i] = cellString(v)
+			}
+			if err := w.Write(rec); err != nil {
+				return fmt.Errorf("unable to encode %s: %w", path, err)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return fmt.Errorf("unable to encode %s: %w", path, err)
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	_, err = tmp.Write(buf.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// trimRow drops trailing empty cells and converts the rest to strings, matching what the
+// Sheets API returns for a row.
+func trimRow(row []interface{}) []interface{} {
+	out := make([]interface{}, len(row))
+	last := -1
+	for i, v := range row {
+		out[i] = cellString(v)
+		if out[i] != "" {
+			last = i
+		}
+	}
+	return out[:last+1]
+}
+
+// trimRows drops trailing empty rows, which the Sheets API also omits.
+func trimRows(rows [][]interface{}) [][]interface{} {
+	for len(rows) > 0 && len(rows[len(rows)-1]) == 0 {
+		rows = rows[:len(rows)-1]
+	}
+	return rows
+}
+
+func idParcela(row []interface{}, col int) string {
+	if len(row) <= col {
+		return ""
+	}
+	return strings.TrimSpace(cellString(row[col]))
+}
+
+func cellString(v interface{}) string {
+	return models.CellText(v)
+}
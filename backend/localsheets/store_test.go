@@ -0,0 +1,203 @@
+package localsheets
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+	"olivia-conciliation/backend/service"
+)
+
+var testDIF = DIFView{Sheet: "DIF", Source: "HOM", Exclude: []string{"ES", "REJ"}}
+
+// newTestStore copia os dados de exemplo de testdata para um diretório temporário.
+func newTestStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir("testdata")
+	if err != nil {
+		t.Fatalf("ReadDir() error: %v", err)
+	}
+	for _, e := range entries {
+		data, err := os.ReadFile(filepath.Join("testdata", e.Name()))
+		if err != nil {
+			t.Fatalf("ReadFile() error: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, e.Name()), data, 0o644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	s, err := NewStore(dir, testDIF)
+	if err != nil {
+		t.Fatalf("NewStore() error: %v", err)
+	}
+	return s, dir
+}
+
+func difIds(t *testing.T, s *Store) []string {
+	t.Helper()
+	rows, err := s.FetchRows(context.Background(), "DIF")
+	if err != nil {
+		t.Fatalf("FetchRows(DIF) error: %v", err)
+	}
+	var ids []string
+	for _, row := range rows[1:] {
+		ids = append(ids, idParcela(row, models.ColumnIdParcela))
+	}
+	return ids
+}
+
+func TestStore_DIFExcludesIdParcelasInESAndREJ(t *testing.T) {
+	s, _ := newTestStore(t)
+
+	got := difIds(t, s)
+	want := []string{"p-moveis-03", "p-padaria-1003", "p-academia-02", "p-salario-0326"}
+	if len(got) != len(want) {
+		t.Fatalf("expected DIF %v, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("DIF row %d: expected %s, got %s", i+1, want[i], got[i])
+		}
+	}
+}
+
+func TestStore_WritesRecomputeDIF(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.WriteCells(ctx, "ES", []models.CellUpdate{{Row: 2, Col: models.ColumnIdParcela, Value: "p-moveis-03"}}); err != nil {
+		t.Fatalf("WriteCells() error: %v", err)
+	}
+	if err := s.DeleteRow(ctx, "REJ", 1); err != nil {
+		t.Fatalf("DeleteRow() error: %v", err)
+	}
+	if err := s.AppendRow(ctx, "REJ", []interface{}{"", "10/03/2026", "PADARIA CENTRAL", "-23,50", "", "", "", "", "Não", "p-padaria-1003"}); err != nil {
+		t.Fatalf("AppendRow() error: %v", err)
+	}
+
+	got := difIds(t, s)
+	want := []string{"p-academia-02", "p-salario-0326", "p-pix-1803"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("expected DIF %v, got %v", want, got)
+	}
+}
+
+func TestStore_DIFFindsIdParcelaByHeader(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		// HOM with IdParcela moved to the front; REJ with its own header name for it.
+		"HOM.csv": "IdParcela,Id,Data,Descrição,Valor,Categoria,Dono,Banco,Conta,Recorrente\n" +
+			"p-1,1,05/03/2026,A,-1,,Fulano,Nubank,Cartão,Não\n" +
+			"p-2,2,05/03/2026,B,-2,,Fulano,Nubank,Cartão,Não\n",
+		"ES.csv":  "Id,Data,Descrição,Valor,Categoria,Dono,Banco,Conta,Recorrente,IdParcela\n",
+		"REJ.csv": "Parcela,Id,Data,Descrição,Valor,Categoria,Dono,Banco,Conta,Recorrente\np-1\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile() error: %v", err)
+		}
+	}
+	view := testDIF
+	view.IdParcela = map[string]string{"REJ": "Parcela"}
+	s, err := NewStore(dir, view)
+	if err != nil {
+		t.Fatalf("NewStore() error: %v", err)
+	}
+
+	rows, err := s.FetchRows(context.Background(), "DIF")
+	if err != nil {
+		t.Fatalf("FetchRows(DIF) error: %v", err)
+	}
+	if len(rows) != 2 || rows[1][0] != "p-2" {
+		t.Errorf("expected only p-2 in the DIF, got %v", rows)
+	}
+
+	view.IdParcela = nil
+	s, _ = NewStore(dir, view)
+	if _, err := s.FetchRows(context.Background(), "DIF"); err == nil {
+		t.Error("expected an error for a REJ without an IdParcela header")
+	}
+}
+
+func TestStore_DIFIsReadOnly(t *testing.T) {
+	s, _ := newTestStore(t)
+	if err := s.WriteCell(context.Background(), "DIF", 1, models.ColumnCategoria, "Casa"); err == nil {
+		t.Error("expected an error writing to the computed DIF")
+	}
+}
+
+func TestStore_KeepsEmptyRowsInPlace(t *testing.T) {
+	s, _ := newTestStore(t)
+	ctx := context.Background()
+
+	// A HOM vai até a linha 6: escrever na 8 deixa a 7 vazia, como na planilha.
+	if err := s.WriteCell(ctx, "HOM", 8, models.ColumnDescricao, "NOVA"); err != nil {
+		t.Fatalf("WriteCell() error: %v", err)
+	}
+	rows, err := s.FetchRows(ctx, "HOM")
+	if err != nil {
+		t.Fatalf("FetchRows() error: %v", err)
+	}
+	if len(rows) != 9 || len(rows[7]) != 0 || rows[8][models.ColumnDescricao] != "NOVA" {
+		t.Errorf("expected an empty row 7 and the new cell at row 8, got %d rows: %v", len(rows), rows[6:])
+	}
+}
+
+func TestStore_JSONTabs(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "AUD.json"), []byte(`[["Timestamp","Ação"],["2026-03-01T10:00:00Z","accept",12.5]]`), 0o644)
+	s, err := NewStore(dir, testDIF)
+	if err != nil {
+		t.Fatalf("NewStore() error: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := s.AppendRow(ctx, "AUD", []interface{}{"2026-03-02T10:00:00Z", "unlink", nil}); err != nil {
+		t.Fatalf("AppendRow() error: %v", err)
+	}
+	rows, err := s.FetchRows(ctx, "AUD")
+	if err != nil {
+		t.Fatalf("FetchRows() error: %v", err)
+	}
+	if len(rows) != 3 || rows[1][2] != "12.5" || len(rows[2]) != 2 {
+		t.Errorf("unexpected rows: %v", rows)
+	}
+}
+
+func TestStore_MissingTab(t *testing.T) {
+	s, _ := newTestStore(t)
+	if _, err := s.FetchRows(context.Background(), "Nope"); err == nil {
+		t.Error("expected an error for a tab without a file")
+	}
+}
+
+func TestStore_ServesTheService(t *testing.T) {
+	s, _ := newTestStore(t)
+	cfg := config.Config{SheetES: "ES", SheetDIF: "DIF", SheetREJ: "REJ", SheetHOM: "HOM", SheetAUD: "AUD",
+		MatchTolerance: config.ToleranceRule{Absolute: config.DefaultMatchTolerance}, MatchMaxGroupSize: 1}
+	logic := service.NewLogic(s, cfg)
+	ctx := context.Background()
+
+	items, err := logic.GetConciliations(ctx)
+	if err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected the 2 recurring DIF rows, got %+v", items)
+	}
+
+	if err := logic.Accept(ctx, 1, models.AcceptRequest{IdParcela: "p-moveis-03", EsRowIndices: []int{2}}); err != nil {
+		t.Fatalf("Accept() error: %v", err)
+	}
+	if got := difIds(t, s); len(got) != 3 || got[0] != "p-padaria-1003" {
+		t.Errorf("expected the accepted row to leave the DIF, got %v", got)
+	}
+	aud, _ := s.FetchRows(ctx, "AUD")
+	if len(aud) != 2 {
+		t.Errorf("expected the Accept in the audit tab, got %v", aud)
+	}
+}
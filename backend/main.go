@@ -10,8 +10,10 @@ import (
 
 	"olivia-conciliation/backend/config"
 	"olivia-conciliation/backend/handlers"
+	"olivia-conciliation/backend/localsheets"
 	"olivia-conciliation/backend/service"
 	"olivia-conciliation/backend/sheets"
+	"olivia-conciliation/backend/sqlstore"
 
 	"github.com/joho/godotenv"
 )
@@ -22,8 +24,16 @@ func main() {
 		log.Printf("warning: failed to load .env: %v", err)
 	}
 
+	cfg := config.FromEnv()
+
+	if len(os.Args) > 1 {
+		if err := runCommand(context.Background(), os.Args[1], cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	requiredEnvVars := []string{
-		"SHEET_SPREADSHEET_ID",
 		"ADMIN_USER",
 		"ADMIN_PASS",
 		"JWT_SECRET",
@@ -32,6 +42,17 @@ func main() {
 		"SHEET_REJ",
 		"SHEET_HOM",
 	}
+	switch cfg.StorageBackend {
+	case config.BackendSheets:
+		requiredEnvVars = append(requiredEnvVars, "SHEET_SPREADSHEET_ID")
+	case config.BackendLocal:
+		requiredEnvVars = append(requiredEnvVars, "LOCAL_DATA_DIR")
+	case config.BackendSQLite:
+		requiredEnvVars = append(requiredEnvVars, "SQLITE_PATH")
+	default:
+		log.Fatalf("unknown STORAGE_BACKEND %q (expected %q, %q or %q)", cfg.StorageBackend,
+			config.BackendSheets, config.BackendLocal, config.BackendSQLite)
+	}
 
 	missingEnvVars := collectMissingEnvVars(requiredEnvVars)
 	if len(missingEnvVars) > 0 {
@@ -39,16 +60,16 @@ func main() {
 		log.Fatal("startup aborted due to missing required env vars")
 	}
 
-	cfg := config.FromEnv()
-
-	// Init Sheets Client
-	client, err := sheets.NewClient(context.Background(), cfg.SpreadsheetID, cfg.SheetES, cfg.SheetREJ)
+	// Init Service
+	store, err := newRepository(cfg)
 	if err != nil {
-		log.Fatalf("Failed to create sheets client: %v", err)
+		log.Fatalf("Failed to create %s repository: %v", cfg.StorageBackend, err)
 	}
-
-	// Init Service
-	svc := service.NewLogic(client, cfg)
+	repo := store
+	if cfg.CacheTTL > 0 {
+		repo = service.NewCachedRepository(store, cfg)
+	}
+	svc := service.NewLogic(repo, cfg)
 
 	// Init Handlers
 	h := handlers.NewHandler(svc, cfg)
@@ -61,9 +82,19 @@ func main() {
 	mux.HandleFunc("/api/logout", h.Logout)
 	mux.HandleFunc("/api/auth/verify", h.Verify)
 
+	// Probes, outside /api so they need no session. /readyz calls the Sheets API, hence the deadline.
+	mux.HandleFunc("/healthz", h.Healthz)
+	mux.Handle("/readyz", h.TimeoutMiddleware(http.HandlerFunc(h.Readyz)))
+
 	// Protected Routes
 	protectedMux := http.NewServeMux()
 	protectedMux.HandleFunc("/api/conciliations", h.GetConciliations)
+	protectedMux.HandleFunc("/api/conciliations/auto", h.AutoConciliate)
+	protectedMux.HandleFunc("/api/conciliations/assignment", h.GetAssignment)
+	protectedMux.HandleFunc("/api/conciliations/unlink", h.UnlinkConciliation)
+	protectedMux.HandleFunc("/api/rej/restore", h.RestoreRejected)
+	protectedMux.HandleFunc("/api/cache/stats", h.GetCacheStats)
+	protectedMux.HandleFunc("/api/diagnostics/rows", h.GetRowDiagnostics)
 	protectedMux.HandleFunc("/api/dif/non-recurring", h.ListNonRecurringDif)
 	protectedMux.HandleFunc("/api/dif/non-recurring/move-all-to-es", h.MoveAllNonRecurringDifToES)
 
@@ -105,8 +136,8 @@ func main() {
 		http.NotFound(w, r)
 	})
 
-	// Mount protected routes with AuthMiddleware
-	mux.Handle("/api/", h.AuthMiddleware(protectedMux))
+	// Mount protected routes with AuthMiddleware and the per-request deadline
+	mux.Handle("/api/", h.AuthMiddleware(h.TimeoutMiddleware(protectedMux)))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -117,6 +148,38 @@ func main() {
 	log.Fatal(http.ListenAndServe(":"+port, mux))
 }
 
+// newRepository builds the storage selected by STORAGE_BACKEND.
+func newRepository(cfg config.Config) (service.SheetRepository, error) {
+	switch cfg.StorageBackend {
+	case config.BackendLocal:
+		log.Printf("Using local data in %s", cfg.LocalDataDir)
+		return localsheets.NewStore(cfg.LocalDataDir, localsheets.DIFView{
+			Sheet:   cfg.SheetDIF,
+			Source:  cfg.SheetHOM,
+			Exclude: []string{cfg.SheetES, cfg.SheetREJ},
+			IdParcela: map[string]string{
+				cfg.SheetHOM: cfg.HeadersFor(cfg.SheetHOM).IdParcela,
+				cfg.SheetES:  cfg.HeadersFor(cfg.SheetES).IdParcela,
+				cfg.SheetREJ: cfg.HeadersFor(cfg.SheetREJ).IdParcela,
+			},
+		})
+	case config.BackendSQLite:
+		log.Printf("Using SQLite database %s", cfg.SQLitePath)
+		return sqlstore.Open(context.Background(), cfg.SQLitePath, sqliteTabs(cfg))
+	}
+
+	tableSheets := []string{cfg.SheetES, cfg.SheetREJ}
+	if cfg.SheetAUD != "" {
+		tableSheets = append(tableSheets, cfg.SheetAUD)
+	}
+	client, err := sheets.NewClient(context.Background(), cfg.SpreadsheetID, retryPolicy(cfg), tableSheets...)
+	if err != nil {
+		return nil, err
+	}
+	client.UseFormattedValues(cfg.SheetsFormattedValues)
+	return client, nil
+}
+
 func collectMissingEnvVars(names []string) []string {
 	missing := make([]string, 0)
 	seen := make(map[string]struct{}, len(names))
@@ -136,3 +199,24 @@ func collectMissingEnvVars(names []string) []string {
 	slices.Sort(missing)
 	return missing
 }
+
+// retryPolicy overrides sheets.DefaultRetryPolicy with whatever the environment configures.
+func retryPolicy(cfg config.Config) sheets.RetryPolicy {
+	p := sheets.DefaultRetryPolicy()
+	if cfg.SheetsMaxAttempts > 0 {
+		p.MaxAttempts = cfg.SheetsMaxAttempts
+	}
+	if cfg.SheetsRetryBaseDelay > 0 {
+		p.BaseDelay = cfg.SheetsRetryBaseDelay
+	}
+	if cfg.SheetsRetryMaxDelay > 0 {
+		p.MaxDelay = cfg.SheetsRetryMaxDelay
+	}
+	if cfg.SheetsRequestsPerMinute > 0 {
+		p.RequestsPerMinute = cfg.SheetsRequestsPerMinute
+	}
+	if cfg.SheetsAttemptTimeout > 0 {
+		p.AttemptTimeout = cfg.SheetsAttemptTimeout
+	}
+	return p
+}
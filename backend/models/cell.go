@@ -0,0 +1,36 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CellText renders a cell value as text. Cells read with UNFORMATTED_VALUE arrive as
+// float64 or bool rather than strings; numbers are written out in full ("12345678",
+// not fmt's "1.2345678e+07") and booleans as the sheet shows them.
+func CellText(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return fmt.Sprint(v)
+}
+
+// ColumnLetter converts a 0-based column index to its A1 letters, e.g. 9 -> "J", 26 -> "AA".
+func ColumnLetter(colIndex int) string {
+	letters := ""
+	for colIndex >= 0 {
+		letters = string(rune('A'+colIndex%26)) + letters
+		colIndex = colIndex/26 - 1
+	}
+	return letters
+}
@@ -0,0 +1,12 @@
+package models
+
+import "testing"
+
+func TestColumnLetter(t *testing.T) {
+	cases := map[int]string{0: "A", 9: "J", 25: "Z", 26: "AA", 27: "AB", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"}
+	for in, want := range cases {
+		if got := ColumnLetter(in); got != want {
+			t.Errorf("ColumnLetter(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+)
+
+// DateLayout is how the sheet and the API show a date.
+const DateLayout = "02/01/2006"
+
+// sheetsEpoch is day zero of the Sheets serial date (the Lotus 1-2-3 convention).
+var sheetsEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// DateFromSerial converts a Sheets serial date; the fraction (time of day) is dropped.
+func DateFromSerial(serial float64) time.Time {
+	return sheetsEpoch.AddDate(0, 0, int(math.Floor(serial)))
+}
+
+// Date is a transaction date as read from the sheet. Raw keeps the original cell
+// text, for cells that could not be parsed; Time is zero in that case.
+type Date struct {
+	Raw  string
+	Time time.Time
+}
+
+// Valid reports whether the date could be parsed.
+func (d Date) Valid() bool {
+	return !d.Time.IsZero()
+}
+
+// Serial is the Sheets serial number of a valid date, the value a date cell holds.
+func (d Date) Serial() float64 {
+	return float64(dayNumber(d.Time) - dayNumber(sheetsEpoch))
+}
+
+// dayNumber counts whole days since the Unix epoch. It works on Unix seconds rather
+// than time.Duration, which overflows about 292 years away from the 1899 epoch.
+func dayNumber(t time.Time) int64 {
+	secs := t.Unix()
+	days := secs / 86400
+	if secs%86400 < 0 {
+		days--
+	}
+	return days
+}
+
+// String shows a valid date as dd/mm/yyyy, whatever format the cell had, and an
+// unparsed one as its original text.
+func (d Date) String() string {
+	if !d.Valid() {
+		return d.Raw
+	}
+	return d.Time.Format(DateLayout)
+}
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON restores only Raw; interpreting it is up to the service Parser.
+func (d *Date) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &d.Raw)
+}
+
+// DaysBetween returns the absolute distance in whole days between two valid dates.
+func DaysBetween(a, b Date) int {
+	days := int(dayNumber(a.Time) - dayNumber(b.Time))
+	if days < 0 {
+		return -days
+	}
+	return days
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDate_SerialRoundTrip(t *testing.T) {
+	d := Date{Time: time.Date(2026, time.June, 14, 0, 0, 0, 0, time.UTC)}
+	if d.Serial() != 46187 {
+		t.Errorf("Serial() = %v, want 46187", d.Serial())
+	}
+	if !DateFromSerial(d.Serial()).Equal(d.Time) {
+		t.Errorf("DateFromSerial(%v) = %v", d.Serial(), DateFromSerial(d.Serial()))
+	}
+}
+
+func TestDate_SerialFarFuture(t *testing.T) {
+	cases := []struct {
+		date time.Time
+		want float64
+	}{
+		{time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC), 146099},
+		{time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC), 2958465},
+	}
+	for _, c := range cases {
+		d := Date{Time: c.date}
+		if d.Serial() != c.want {
+			t.Errorf("Serial(%s) = %v, want %v", d, d.Serial(), c.want)
+		}
+		if !DateFromSerial(d.Serial()).Equal(c.date) {
+			t.Errorf("DateFromSerial(%v) = %v, want %v", d.Serial(), DateFromSerial(d.Serial()), c.date)
+		}
+	}
+	a := Date{Time: time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	b := Date{Time: time.Date(2300, time.January, 1, 0, 0, 0, 0, time.UTC)}
+	if got := DaysBetween(a, b); got != 146097 {
+		t.Errorf("DaysBetween = %d, want 146097", got)
+	}
+}
+
+func TestDate_MarshalJSON(t *testing.T) {
+	cases := []struct {
+		date Date
+		want string
+	}{
+		{Date{Raw: "2026-06-14", Time: time.Date(2026, time.June, 14, 0, 0, 0, 0, time.UTC)}, `"14/06/2026"`},
+		{Date{Raw: "ontem"}, `"ontem"`},
+	}
+	for _, c := range cases {
+		b, err := json.Marshal(c.date)
+		if err != nil || string(b) != c.want {
+			t.Errorf("Marshal(%+v) = %s, %v; want %s", c.date, b, err, c.want)
+		}
+	}
+}
@@ -1,7 +1,7 @@
 package models
 
-// Column indices (A=1, so index 0 would be A-1, but Sheets API GridRange sometimes uses 0-based.
-// However, when parsing a row slice []interface{}, index 0 is Column A.
+// Column indices of the default layout, the one bootstrap writes (index 0 is column A).
+// The service does not rely on them: it finds each column by its header, per tab (ColumnMap).
 const (
 	ColumnData       = 1 // B
 	ColumnDescricao  = 2 // C
@@ -14,25 +14,110 @@ const (
 	ColumnIdParcela  = 9 // J
 )
 
+// TransactionHeader is the header row of the transaction tabs (ES, DIF, HOM, REJ), columns A:J.
+// Column A is not read by the backend.
+var TransactionHeader = []string{"Id", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente", "IdParcela"}
+
+// ColumnMap is where each transaction field sits in one tab, as indices into the row slice.
+type ColumnMap struct {
+	Data, Descricao, Valor, Categoria, Dono, Banco, Conta, Recorrente, IdParcela int
+}
+
+// DefaultColumns is the layout of TransactionHeader.
+var DefaultColumns = ColumnMap{
+	Data: ColumnData, Descricao: ColumnDescricao, Valor: ColumnValor, Categoria: ColumnCategoria,
+	Dono: ColumnDono, Banco: ColumnBanco, Conta: ColumnConta, Recorrente: ColumnRecorrente,
+	IdParcela: ColumnIdParcela,
+}
+
+// Fields lists the indices in a fixed field order, Data first and IdParcela last, so two
+// maps can be walked field by field.
+func (c ColumnMap) Fields() []int {
+	return []int{c.Data, c.Descricao, c.Valor, c.Categoria, c.Dono, c.Banco, c.Conta, c.Recorrente, c.IdParcela}
+}
+
 // Transaction represents a row in the spreadsheet (ES or DIF)
 type Transaction struct {
-	RowIndex   int     `json:"rowIndex"` // 0-based index in the sheet
-	Dono       string  `json:"dono"`
-	Banco      string  `json:"banco"`
-	Conta      string  `json:"conta"`
-	Descricao  string  `json:"descricao"`
-	Recorrente bool    `json:"recorrente"`
-	Data       string  `json:"data"`
-	Valor      float64 `json:"valor"`
-	Categoria  string  `json:"categoria"`
-	IdParcela  string  `json:"idParcela"`
-	Sheet      string  `json:"sheet"` // "ES" or "DIF"
+	RowIndex   int    `json:"rowIndex"` // 0-based index in the sheet
+	Dono       string `json:"dono"`
+	Banco      string `json:"banco"`
+	Conta      string `json:"conta"`
+	Descricao  string `json:"descricao"`
+	Recorrente bool   `json:"recorrente"`
+	Data       Date   `json:"data"`
+	Valor      Money  `json:"valor"`
+	Categoria  string `json:"categoria"`
+	IdParcela  string `json:"idParcela"`
+	Sheet      string `json:"sheet"` // "ES" or "DIF"
+	// Parcela/TotalParcelas come from "PARC 03/10" or "3/10" in Descricao; 0 when absent
+	Parcela       int `json:"parcela"`
+	TotalParcelas int `json:"totalParcelas"`
+}
+
+// CellProblem is a cell the strict parser could not read
+type CellProblem struct {
+	Column string `json:"column"` // column letter in the tab
+	Field  string `json:"field"`  // Valor, Data, Recorrente or Dono
+	Value  string `json:"value"`
+	Reason string `json:"reason"` // invalid_amount, invalid_date, invalid_recorrente or missing_dono
+}
+
+// RowDiagnostic lists the unreadable cells of one row of a tab
+type RowDiagnostic struct {
+	Sheet     string        `json:"sheet"`
+	RowIndex  int           `json:"rowIndex"` // 0-based, the header is row 0
+	IdParcela string        `json:"idParcela"`
+	Problems  []CellProblem `json:"problems"`
+}
+
+// AppliedTolerance describes the tolerance rule used to match candidates against a reference
+type AppliedTolerance struct {
+	Rule     string  `json:"rule"` // "default" or the Dono/Banco/Conta override that applied
+	Absolute Money   `json:"absolute"`
+	Percent  float64 `json:"percent"`
+	Limit    Money   `json:"limit"` // exclusive maximum difference in Valor for this reference
+}
+
+// ScoreBreakdown holds each component of a match score, all in [0, 1]
+type ScoreBreakdown struct {
+	Valor     float64 `json:"valor"`
+	Data      float64 `json:"data"`
+	Descricao float64 `json:"descricao"`
+	Parcela   float64 `json:"parcela"`
+}
+
+// ScoredCandidate is an ES candidate ranked against the DIF reference
+type ScoredCandidate struct {
+	Transaction
+	Score      float64        `json:"score"` // weighted sum of ScoreParts, higher is better
+	ScoreParts ScoreBreakdown `json:"scoreParts"`
+	ClaimedBy  []int          `json:"claimedBy,omitempty"` // other DIF rows that also have this ES row as candidate
+	// OutOfWindow marks candidates whose Data is farther from the reference than the configured window
+	OutOfWindow bool `json:"outOfWindow"`
+}
+
+// CandidateGroup is a combination of rows whose summed Valor settles a single row on the
+// other side: "split" groups several ES rows for the reference; "merge" groups the reference
+// with other DIF rows against one ES row
+type CandidateGroup struct {
+	Kind          string        `json:"kind"`
+	DifRowIndices []int         `json:"difRowIndices"`
+	EsRowIndices  []int         `json:"esRowIndices"`
+	Rows          []Transaction `json:"rows"`       // the grouped side
+	Total         Money         `json:"total"`      // sum of Rows
+	Difference    Money         `json:"difference"` // Total minus the single counterpart's Valor
 }
 
 // ConciliationCandidate represents a potential match
 type ConciliationCandidate struct {
-	Reference  Transaction   `json:"reference"`  // From DIF
-	Candidates []Transaction `json:"candidates"` // From ES
+	Reference  Transaction       `json:"reference"`  // From DIF
+	Candidates []ScoredCandidate `json:"candidates"` // From ES, best score first
+	Tolerance  AppliedTolerance  `json:"tolerance"`
+	Groups     []CandidateGroup  `json:"groups"` // split/merge combinations, closest sum first
+	// DateWindowDays is the ± day window around the reference Data; 0 when disabled
+	DateWindowDays int `json:"dateWindowDays"`
+	// ProposedEsRowIndex is the ES row given to this reference by the global assignment; 0 when none
+	ProposedEsRowIndex int `json:"proposedEsRowIndex"`
 }
 
 // PendingConciliationSummary is a lightweight view for the list
@@ -43,26 +128,146 @@ type PendingConciliationSummary struct {
 	Banco          string  `json:"banco"`
 	Conta          string  `json:"conta"`
 	Descricao      string  `json:"descricao"`
-	Data           string  `json:"data"`
-	Valor          float64 `json:"valor"`
+	Data           Date    `json:"data"`
+	Valor          Money   `json:"valor"`
+	Parcela        int     `json:"parcela"`
+	TotalParcelas  int     `json:"totalParcelas"`
 	CandidateCount int     `json:"candidateCount"`
+	TopScore       float64 `json:"topScore"` // best candidate score, 0 when there are none
+	// ProposedEsRowIndex is the ES row given by the global assignment; 0 when none
+	ProposedEsRowIndex int  `json:"proposedEsRowIndex"`
+	Contested          bool `json:"contested"` // some candidate is also claimed by other DIF rows
+}
+
+// AssignmentPair is one DIF → ES pair of the globally optimal assignment
+type AssignmentPair struct {
+	DifRowIndex int     `json:"difRowIndex"`
+	IdParcela   string  `json:"idParcela"`
+	EsRowIndex  int     `json:"esRowIndex"`
+	Score       float64 `json:"score"`
+	Contested   bool    `json:"contested"` // the ES row is also a candidate of other DIF rows
+}
+
+// EsRowClaim lists the DIF rows that have the same pending ES row as candidate
+type EsRowClaim struct {
+	EsRowIndex          int   `json:"esRowIndex"`
+	DifRowIndices       []int `json:"difRowIndices"`       // ascending
+	AssignedDifRowIndex int   `json:"assignedDifRowIndex"` // -1 when the assignment left the row free
+}
+
+// ConciliationAssignment is the global DIF ↔ ES pairing proposed for the whole queue
+type ConciliationAssignment struct {
+	Pairs      []AssignmentPair `json:"pairs"`
+	Conflicts  []EsRowClaim     `json:"conflicts"`
+	Unassigned []int            `json:"unassignedDifRowIndices"`
+}
+
+// CacheStats reports how the sheet read cache has been doing since startup
+type CacheStats struct {
+	Enabled       bool    `json:"enabled"`
+	TTLSeconds    float64 `json:"ttlSeconds"`
+	Entries       int     `json:"entries"`
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	Invalidations uint64  `json:"invalidations"`
+}
+
+// HealthCheck is the outcome of one readiness check
+type HealthCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ReadinessReport is what the readiness checks found: ready only when every check passed
+type ReadinessReport struct {
+	Ready  bool          `json:"ready"`
+	Checks []HealthCheck `json:"checks"`
+}
+
+// ReadinessStatus is the public /readyz response: the names of the failing checks, without
+// their details, which stay in the server log
+type ReadinessStatus struct {
+	Ready   bool     `json:"ready"`
+	Failing []string `json:"failing"`
+}
+
+// CellUpdate is one cell of a batched write, addressed like FetchRows (0-based, header included)
+type CellUpdate struct {
+	Row   int
+	Col   int
+	Value string
 }
 
-// AcceptRequest defines the body for accepting a conciliation
+// AcceptRequest defines the body for accepting a conciliation.
+// IdParcela and ExpectedEsRows are what the client saw when listing; they are optional
+// and, when sent, the accept is refused if the rows no longer carry that content.
 type AcceptRequest struct {
-	EsRowIndices []int `json:"esRowIndices"`
+	IdParcela      string          `json:"idParcela,omitempty"`
+	EsRowIndices   []int           `json:"esRowIndices"`
+	ExpectedEsRows []ExpectedEsRow `json:"expectedEsRows,omitempty"`
 }
 
-type NonRecurringDifSummary struct {
+// ExpectedEsRow is the content of an ES row as the client saw it
+type ExpectedEsRow struct {
+	EsRowIndex int    `json:"esRowIndex"`
+	IdParcela  string `json:"idParcela"`
+	Descricao  string `json:"descricao"`
+	Valor      Money  `json:"valor"`
+}
+
+// RowActionRequest defines the optional body for actions addressed by DIF row index
+// (reject, move to ES/REJ): the IdParcela the client saw in that row.
+type RowActionRequest struct {
+	IdParcela string `json:"idParcela"`
+}
+
+// AcceptRowError explains why one of the requested ES rows cannot be accepted
+type AcceptRowError struct {
+	EsRowIndex int    `json:"esRowIndex"`
+	Reason     string `json:"reason"` // not_found, duplicate, not_pending, other_account, mismatch
+	Detail     string `json:"detail"`
+}
+
+// AcceptErrorResponse is the body returned when an accept request fails validation
+type AcceptErrorResponse struct {
+	Error string           `json:"error"`
+	Rows  []AcceptRowError `json:"rows"`
+}
+
+// AutoConciliationRequest defines the body for the auto-conciliation run.
+// Without Commit the run is a dry-run that only proposes pairs.
+type AutoConciliationRequest struct {
+	Commit bool `json:"commit"`
+}
+
+// AutoConciliationItem is one DIF → ES pair proposed (or accepted) by the auto-conciliation
+type AutoConciliationItem struct {
 	DifRowIndex int     `json:"difRowIndex"`
-	Dono        string  `json:"dono"`
-	Banco       string  `json:"banco"`
-	Conta       string  `json:"conta"`
-	Descricao   string  `json:"descricao"`
-	Data        string  `json:"data"`
-	Valor       float64 `json:"valor"`
-	Categoria   string  `json:"categoria"`
 	IdParcela   string  `json:"idParcela"`
+	EsRowIndex  int     `json:"esRowIndex"`
+	Score       float64 `json:"score"`
+	Status      string  `json:"status"` // "proposed", "accepted" or "failed"
+	Error       string  `json:"error,omitempty"`
+}
+
+type AutoConciliationResult struct {
+	DryRun   bool                   `json:"dryRun"`
+	MinScore float64                `json:"minScore"`
+	Items    []AutoConciliationItem `json:"items"`
+	Skipped  int                    `json:"skipped"` // recurring DIF rows left for manual review
+}
+
+type NonRecurringDifSummary struct {
+	DifRowIndex int    `json:"difRowIndex"`
+	Dono        string `json:"dono"`
+	Banco       string `json:"banco"`
+	Conta       string `json:"conta"`
+	Descricao   string `json:"descricao"`
+	Data        Date   `json:"data"`
+	Valor       Money  `json:"valor"`
+	Categoria   string `json:"categoria"`
+	IdParcela   string `json:"idParcela"`
 }
 
 type UpdateCategoryRequest struct {
@@ -75,6 +280,26 @@ type UpdateDateRequest struct {
 	Data      string `json:"data"`
 }
 
+// UnlinkRequest defines the body for undoing an accepted conciliation
+type UnlinkRequest struct {
+	IdParcela string `json:"idParcela"`
+}
+
+type UnlinkedRow struct {
+	EsRowIndex        int    `json:"esRowIndex"`
+	RestoredIdParcela string `json:"restoredIdParcela"` // synthetic ID put back, or "" when cleared
+}
+
+type UnlinkResult struct {
+	IdParcela string        `json:"idParcela"`
+	Rows      []UnlinkedRow `json:"rows"`
+}
+
+// RestoreRequest defines the body for bringing a rejected transaction back from REJ
+type RestoreRequest struct {
+	IdParcela string `json:"idParcela"`
+}
+
 type NonRecurringBulkActionResult struct {
 	MovedToES int `json:"movedToES"`
 }
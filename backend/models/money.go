@@ -0,0 +1,142 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount in cents. Sums and differences of Money are exact; float64 only
+// shows up where a ratio is needed (scores, percent tolerances).
+type Money int64
+
+// MoneyFromFloat rounds an amount in reais to the nearest cent.
+func MoneyFromFloat(reais float64) Money {
+	return Money(math.Round(reais * 100))
+}
+
+// ParseMoney reads an amount as the spreadsheet or the UI writes it: "1234.56",
+// "-50", "1.234,56" or "R$ -1.234,56". A dot-decimal reading is tried first, then pt-BR.
+// Digits past the cents are rounded half away from zero.
+func ParseMoney(s string) (Money, bool) {
+	s = strings.TrimSpace(strings.ReplaceAll(s, "R$", ""))
+	if m, ok := parseDecimal(s); ok {
+		return m, true
+	}
+	// pt-BR format: 1.000,00 → remove thousands dot, replace decimal comma
+	s = strings.ReplaceAll(s, ".", "")
+	return parseDecimal(strings.ReplaceAll(s, ",", "."))
+}
+
+// parseDecimal reads [sign]digits[.digits] without going through float64.
+func parseDecimal(s string) (Money, bool) {
+	neg := false
+	switch {
+	case strings.HasPrefix(s, "-"):
+		neg, s = true, strings.TrimSpace(s[1:])
+	case strings.HasPrefix(s, "+"):
+		s = strings.TrimSpace(s[1:])
+	}
+	intPart, frac, _ := strings.Cut(s, ".")
+	if intPart == "" && frac == "" {
+		return 0, false
+	}
+	for _, part := range []string{intPart, frac} {
+		if strings.Trim(part, "0123456789") != "" {
+			return 0, false
+		}
+	}
+
+	var reais int64
+	if intPart != "" {
+		n, err := strconv.ParseInt(intPart, 10, 64)
+		if err != nil || n > math.MaxInt64/100 {
+			return 0, false
+		}
+		reais = n
+	}
+	digits := (frac + "000")[:3]
+	cents, _ := strconv.ParseInt(digits[:2], 10, 64)
+	if digits[2] >= '5' {
+		cents++
+	}
+
+	m := Money(reais*100 + cents)
+	if neg {
+		m = -m
+	}
+	return m, true
+}
+
+// Abs returns the amount without its sign.
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// Reais converts to a float amount in reais, for ratios and for the Sheets NumberValue.
+func (m Money) Reais() float64 {
+	return float64(m) / 100
+}
+
+// Percent returns p percent of m, rounded to the cent.
+func (m Money) Percent(p float64) Money {
+	return MoneyFromFloat(m.Reais() * p / 100)
+}
+
+// Decimal formats with a dot and no thousands separator: "-1234.56".
+func (m Money) Decimal() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	a := int64(m.Abs())
+	return fmt.Sprintf("%s%d.%02d", sign, a/100, a%100)
+}
+
+// String formats in pt-BR, as the spreadsheet shows it: "-1.234,56". ParseMoney reads
+// it back unchanged, so rows written by the local and SQLite backends round-trip.
+func (m Money) String() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+	}
+	a := int64(m.Abs())
+	whole := strconv.FormatInt(a/100, 10)
+	var b strings.Builder
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(r)
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, b.String(), a%100)
+}
+
+// MarshalJSON writes the amount as a decimal string ("-1234.56"): exact, and still
+// readable with Number() in the browser.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Decimal())
+}
+
+// UnmarshalJSON takes the string MarshalJSON writes, or a plain JSON number.
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(b, &n); err != nil {
+			return fmt.Errorf("invalid amount %s", b)
+		}
+		s = n.String()
+	}
+	v, ok := ParseMoney(s)
+	if !ok {
+		return fmt.Errorf("invalid amount %q", s)
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+		ok   bool
+	}{
+		{"1234.56", 123456, true},
+		{"-50", -5000, true},
+		{"1.234,56", 123456, true},
+		{"R$ -1.234,56", -123456, true},
+		{"0,1", 10, true},
+		{"10.005", 1001, true},
+		{"-0.005", -1, true},
+		{"", 0, false},
+		{"abc", 0, false},
+		{"1,2,3", 0, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseMoney(c.in)
+		if got != c.want || ok != c.ok {
+			t.Errorf("ParseMoney(%q) = %d, %v; want %d, %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
+
+func TestMoney_SumsAreExact(t *testing.T) {
+	var sum Money
+	for i := 0; i < 10; i++ {
+		sum += MoneyFromFloat(0.1)
+	}
+	if sum != MoneyFromFloat(1) {
+		t.Errorf("ten times 0,10 = %s, want 1,00", sum)
+	}
+}
+
+func TestMoney_Format(t *testing.T) {
+	m := Money(-123456789)
+	if got := m.String(); got != "-1.234.567,89" {
+		t.Errorf("String() = %q", got)
+	}
+	if got := m.Decimal(); got != "-1234567.89" {
+		t.Errorf("Decimal() = %q", got)
+	}
+	if back, ok := ParseMoney(m.String()); !ok || back != m {
+		t.Errorf("String() does not round-trip: %d, %v", back, ok)
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	b, err := json.Marshal(struct{ Valor Money }{Valor: -5005})
+	if err != nil || string(b) != `{"Valor":"-50.05"}` {
+		t.Fatalf("Marshal = %s, %v", b, err)
+	}
+	var v struct{ A, B Money }
+	if err := json.Unmarshal([]byte(`{"A":"-50.05","B":12.5}`), &v); err != nil {
+		t.Fatalf("Unmarshal error: %v", err)
+	}
+	if v.A != -5005 || v.B != 1250 {
+		t.Errorf("Unmarshal = %+v", v)
+	}
+	if err := json.Unmarshal([]byte(`{"A":true}`), &v); err == nil {
+		t.Error("expected an error for a non-amount")
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"olivia-conciliation/backend/models"
+)
+
+// infeasibleCost marca pares DIF/ES que não são Candidatas entre si. É ordens de
+// grandeza maior que qualquer custo real (1 - pontuação ∈ [0, 1]), então o algoritmo
+// só recorre a ele quando não há par melhor — e esses pares são descartados depois.
+const infeasibleCost = 1e6
+
+// accountKey agrupa transações por Dono/Banco/Conta: só há Candidatas dentro do grupo,
+// então cada grupo é resolvido de forma independente.
+type accountKey struct{ dono, banco, conta string }
+
+// assignment é o resultado interno de assign, indexado por linha da planilha.
+type assignment struct {
+	byDif  map[int]models.ScoredCandidate // DIF rowIndex → Candidata atribuída
+	claims map[int][]int                  // ES rowIndex → DIF rowIndex que a têm como Candidata
+}
+
+// assign calcula a atribuição DIF↔ES de custo mínimo (Kuhn-Munkres) sobre 1 - pontuação.
+// Ao contrário da contagem independente por linha, cada linha pendente da ES vai para
+// no máximo uma DIF, evitando que três Aceitar sobrescrevam o IdParcela da mesma linha.
+// Maximiza primeiro o número de pares e, entre as atribuições com esse número, a soma
+// das pontuações.
+func (l *Logic) assign(recurring, pending []models.Transaction) assignment {
+	result := assignment{
+		byDif:  make(map[int]models.ScoredCandidate),
+		claims: make(map[int][]int),
+	}
+
+	difByKey := make(map[accountKey][]models.Transaction)
+	var keys []accountKey
+	for _, dif := range recurring {
+		k := accountKey{dif.Dono, dif.Banco, dif.Conta}
+		if _, ok := difByKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		difByKey[k] = append(difByKey[k], dif)
+	}
+	esByKey := make(map[accountKey][]models.Transaction)
+	for _, es := range pending {
+		k := accountKey{es.Dono, es.Banco, es.Conta}
+		esByKey[k] = append(esByKey[k], es)
+	}
+
+	for _, k := range keys {
+		difs, ess := difByKey[k], esByKey[k]
+		if len(ess) == 0 {
+			continue
+		}
+
+		scored := make([][]*models.ScoredCandidate, len(difs))
+		cost := make([][]float64, len(difs))
+		for i, dif := range difs {
+			scored[i] = make([]*models.ScoredCandidate, len(ess))
+			cost[i] = make([]float64, len(ess))
+			tol := l.toleranceFor(dif)
+			for j, es := range ess {
+				cost[i][j] = infeasibleCost
+				if !isMatch(dif, es, tol) || !l.inDateWindow(dif, es) {
+					continue
+				}
+				sc := l.scoreCandidate(dif, es, tol)
+				scored[i][j] = &sc
+				cost[i][j] = 1 - sc.Score
+				result.claims[es.RowIndex] = append(result.claims[es.RowIndex], dif.RowIndex)
+			}
+		}
+
+		for i, j := range minCostAssignment(cost) {
+			if j >= 0 && scored[i][j] != nil {
+				result.byDif[difs[i].RowIndex] = *scored[i][j]
+			}
+		}
+	}
+
+	return result
+}
+
+// contested informa se a linha da ES é Candidata de mais de uma DIF.
+func (a assignment) contested(esRowIndex int) bool {
+	return len(a.claims[esRowIndex]) > 1
+}
+
+// GetAssignment devolve a atribuição global proposta e as linhas da ES disputadas
+// por mais de uma Transação Parcelada da DIF.
+func (l *Logic) GetAssignment(ctx context.Context) (*models.ConciliationAssignment, error) {
+	difSheet, esSheet, err := l.fetchDIFAndES(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	recurring := l.recurringDIF(difSheet)
+	a := l.assign(recurring, l.pendingES(esSheet))
+
+	result := &models.ConciliationAssignment{
+		Pairs:      make([]models.AssignmentPair, 0),
+		Conflicts:  make([]models.EsRowClaim, 0),
+		Unassigned: make([]int, 0),
+	}
+	assignedTo := make(map[int]int)
+	for _, dif := range recurring {
+		sc, ok := a.byDif[dif.RowIndex]
+		if !ok {
+			result.Unassigned = append(result.Unassigned, dif.RowIndex)
+			continue
+		}
+		assignedTo[sc.RowIndex] = dif.RowIndex
+		result.Pairs = append(result.Pairs, models.AssignmentPair{
+			DifRowIndex: dif.RowIndex,
+			IdParcela:   dif.IdParcela,
+			EsRowIndex:  sc.RowIndex,
+			Score:       sc.Score,
+			Contested:   a.contested(sc.RowIndex),
+		})
+	}
+
+	for esIdx, difs := range a.claims {
+		if len(difs) < 2 {
+			continue
+		}
+		assigned, ok := assignedTo[esIdx]
+		if !ok {
+			assigned = -1
+		}
+		// As DIFs chegam na ordem em que reivindicaram a linha: ordenadas, a resposta
+		// não muda de uma chamada para outra.
+		sorted := append([]int(nil), difs...)
+		sort.Ints(sorted)
+		result.Conflicts = append(result.Conflicts, models.EsRowClaim{
+			EsRowIndex:          esIdx,
+			DifRowIndices:       sorted,
+			AssignedDifRowIndex: assigned,
+		})
+	}
+	sort.Slice(result.Conflicts, func(i, j int) bool {
+		return result.Conflicts[i].EsRowIndex < result.Conflicts[j].EsRowIndex
+	})
+
+	return result, nil
+}
+
+// minCostAssignment resolve o problema de atribuição retangular pelo método húngaro
+// (versão com potenciais, O(n²·m)). Devolve, para cada linha de cost, a coluna
+// atribuída ou -1. Pares com infeasibleCost podem aparecer e cabe ao chamador descartá-los.
+func minCostAssignment(cost [][]float64) []int {
+	n := len(cost)
+	if n == 0 {
+		return nil
+	}
+	m := len(cost[0])
+
+	// O algoritmo exige linhas ≤ colunas; com mais DIF que ES, resolve a transposta.
+	if n > m {
+		t := make([][]float64, m)
+		for j := range t {
+			t[j] = make([]float64, n)
+			for i := range cost {
+				t[j][i] = cost[i][j]
+			}
+		}
+		cols := minCostAssignment(t)
+		rows := make([]int, n)
+		for i := range rows {
+			rows[i] = -1
+		}
+		for j, i := range cols {
+			if i >= 0 {
+				rows[i] = j
+			}
+		}
+		return rows
+	}
+
+	// Índices 1-based com a coluna 0 como sentinela, como na formulação clássica.
+	u := make([]float64, n+1)
+	v := make([]float64, m+1)
+	p := make([]int, m+1)
+	way := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		minv := make([]float64, m+1)
+		used := make([]bool, m+1)
+		for j := range minv {
+			minv[j] = math.Inf(1)
+		}
+		for {
+			used[j0] = true
+			i0 := p[j0]
+			delta := math.Inf(1)
+			j1 := 0
+			for j := 1; j <= m; j++ {
+				if used[j] {
+					continue
+				}
+				cur := cost[i0-1][j-1] - u[i0] - v[j]
+				if cur < minv[j] {
+					minv[j] = cur
+					way[j] = j0
+				}
+				if minv[j] < delta {
+					delta = minv[j]
+					j1 = j
+				}
+			}
+			for j := 0; j <= m; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+			if p[j0] == 0 {
+				break
+			}
+		}
+		for {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+			if j0 == 0 {
+				break
+			}
+		}
+	}
+
+	rows := make([]int, n)
+	for i := range rows {
+		rows[i] = -1
+	}
+	for j := 1; j <= m; j++ {
+		if p[j] > 0 {
+			rows[p[j]-1] = j - 1
+		}
+	}
+	return rows
+}
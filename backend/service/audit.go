@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"olivia-conciliation/backend/models"
+)
+
+// Ações registradas na trilha de auditoria.
+const (
+	AuditActionAccept  = "accept"
+	AuditActionUnlink  = "unlink"
+	AuditActionRestore = "restore"
+)
+
+// Colunas da aba de auditoria (SHEET_AUD), na ordem em que a linha é anexada.
+const (
+	auditColumnTimestamp = iota
+	auditColumnAction
+	auditColumnIdParcela
+	auditColumnSheet
+	auditColumnRow
+	auditColumnBefore
+	auditColumnAfter
+)
+
+// AuditHeader é o cabeçalho esperado da aba de auditoria.
+var AuditHeader = []interface{}{"Quando", "Ação", "IdParcela", "Aba", "Linha", "Antes", "Depois"}
+
+// auditEntry é uma mudança de célula feita pelo backend.
+type auditEntry struct {
+	action    string
+	idParcela string
+	sheet     string
+	row       int
+	// rows substitui row quando uma só mudança apaga várias linhas (Restaurar).
+	rows   []int
+	before string
+	after  string
+}
+
+// line é o conteúdo da coluna Linha: a linha, ou as linhas separadas por vírgula.
+func (e auditEntry) line() string {
+	if len(e.rows) == 0 {
+		return strconv.Itoa(e.row)
+	}
+	parts := make([]string, len(e.rows))
+	for i, r := range e.rows {
+		parts[i] = strconv.Itoa(r)
+	}
+	return strings.Join(parts, ",")
+}
+
+// audit registra as mudanças no log e, se SHEET_AUD estiver configurada, anexa uma linha
+// por mudança na aba de auditoria, numa única chamada. Uma falha aqui não desfaz a escrita
+// que já aconteceu na planilha, então é só logada: o log continua sendo a trilha de último
+// recurso. A escrita auditada já aconteceu, então o registro não é abortado se o cliente
+// desistir da requisição no meio (só o prazo por chamada do repositório vale).
+func (l *Logic) audit(ctx context.Context, entries ...auditEntry) {
+	ctx = context.WithoutCancel(ctx)
+	now := time.Now().UTC().Format(time.RFC3339)
+	rows := make([][]interface{}, 0, len(entries))
+	for _, e := range entries {
+		log.Printf("audit: %s idParcela=%q %s!%s %q -> %q", e.action, e.idParcela, e.sheet, e.line(), e.before, e.after)
+		rows = append(rows, []interface{}{
+			now,
+			e.action,
+			e.idParcela,
+			e.sheet,
+			e.line(),
+			e.before,
+			e.after,
+		})
+	}
+	if l.cfg.SheetAUD == "" || len(rows) == 0 {
+		return
+	}
+	if err := l.repo.AppendRows(ctx, l.cfg.SheetAUD, rows); err != nil {
+		log.Printf("warning: failed to append %d audit entries: %v", len(rows), err)
+	}
+}
+
+// previousIdParcela procura nas linhas da auditoria o valor que a coluna IdParcela da ES
+// tinha antes do Aceitar que gravou idParcela na linha row. Prefere o registro da mesma
+// linha e, entre vários, o mais recente; sem registro (ou sem SHEET_AUD), devolve "".
+func (l *Logic) previousIdParcela(rows [][]interface{}, idParcela string, row int) string {
+	previous, sameRow := "", false
+	for i := 1; i < len(rows); i++ {
+		r := rows[i]
+		if len(r) <= auditColumnBefore ||
+			cellString(r, auditColumnAction) != AuditActionAccept ||
+			cellString(r, auditColumnIdParcela) != idParcela ||
+			cellString(r, auditColumnSheet) != l.cfg.SheetES {
+			continue
+		}
+		matchesRow := cellString(r, auditColumnRow) == strconv.Itoa(row)
+		if sameRow && !matchesRow {
+			continue
+		}
+		previous, sameRow = cellString(r, auditColumnBefore), matchesRow
+	}
+	return previous
+}
+
+func cellString(row []interface{}, col int) string {
+	if col >= len(row) || row[col] == nil {
+		return ""
+	}
+	return strings.TrimSpace(models.CellText(row[col]))
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+const (
+	AutoStatusProposed = "proposed"
+	AutoStatusAccepted = "accepted"
+	AutoStatusFailed   = "failed"
+)
+
+// AutoConciliate percorre as Transações Parceladas da DIF e propõe o Aceitar das que
+// têm exatamente uma Candidata com pontuação mínima, e só quando a atribuição global
+// lhe dá essa mesma linha da ES. Uma linha da ES disputada por mais de uma DIF fica de
+// fora: escolher entre elas é decisão humana.
+//
+// Com commit=false nada é escrito (dry-run). Com commit=true cada par passa pela mesma
+// validação do Aceitar manual, contra a mesma leitura que gerou as propostas, e os
+// aprovados são gravados na ES numa única escrita, com uma única chamada de auditoria.
+// O resultado é reportado item a item: um par recusado não impede os demais; uma falha
+// na escrita marca como falhos todos os que iam nela.
+func (l *Logic) AutoConciliate(ctx context.Context, commit bool) (*models.AutoConciliationResult, error) {
+	if commit {
+		ctx = withFreshReads(ctx)
+	}
+	difSheet, esSheet, err := l.fetchDIFAndES(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	minScore := l.cfg.AutoConciliationMinScore
+	if minScore <= 0 {
+		minScore = config.DefaultAutoConciliationMinScore
+	}
+
+	pending := l.pendingES(esSheet)
+	recurring := l.recurringDIF(difSheet)
+	a := l.assign(recurring, pending)
+
+	items := make([]models.AutoConciliationItem, 0)
+	var staged []int // índices em items que entram na escrita
+	var cells []models.CellUpdate
+	var entries []auditEntry
+	for _, dif := range recurring {
+		ranked, _ := l.rankCandidates(dif, pending)
+		ranked = inWindowOnly(ranked)
+		if len(ranked) != 1 || ranked[0].Score < minScore || dif.IdParcela == "" {
+			continue
+		}
+		es := ranked[0].RowIndex
+		if a.byDif[dif.RowIndex].RowIndex != es || a.contested(es) {
+			continue
+		}
+
+		item := models.AutoConciliationItem{
+			DifRowIndex: dif.RowIndex,
+			IdParcela:   dif.IdParcela,
+			EsRowIndex:  es,
+			Score:       ranked[0].Score,
+			Status:      AutoStatusProposed,
+		}
+		if commit {
+			c, e, err := l.acceptChanges(difSheet, esSheet, item.DifRowIndex, models.AcceptRequest{
+				IdParcela:    item.IdParcela,
+				EsRowIndices: []int{item.EsRowIndex},
+			})
+			if err != nil {
+				item.Status = AutoStatusFailed
+				item.Error = err.Error()
+			} else {
+				staged = append(staged, len(items))
+				cells = append(cells, c...)
+				entries = append(entries, e...)
+			}
+		}
+		items = append(items, item)
+	}
+
+	if len(cells) > 0 {
+		status, errText := AutoStatusAccepted, ""
+		if err := l.repo.WriteCells(ctx, l.cfg.SheetES, cells); err != nil {
+			status, errText = AutoStatusFailed, err.Error()
+		} else {
+			l.audit(ctx, entries...)
+		}
+		for _, i := range staged {
+			items[i].Status, items[i].Error = status, errText
+		}
+	}
+
+	return &models.AutoConciliationResult{
+		DryRun:   !commit,
+		MinScore: minScore,
+		Items:    items,
+		Skipped:  len(recurring) - len(items),
+	}, nil
+}
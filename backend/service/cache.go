@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+// CachedRepository guarda por alguns segundos as abas lidas de outro SheetRepository,
+// para que navegar pela fila não baixe DIF e ES inteiras a cada clique.
+//
+// Toda escrita feita pelo backend invalida a aba escrita e as que dependem dela: a DIF é
+// uma fórmula sobre HOM, ES e REJ, então escrever em qualquer uma das três também a
+// invalida. Escritas feitas por fora (o Processamento de Transações reescrevendo a HOM,
+// edições à mão) só aparecem quando o TTL vence, por isso ele deve ser curto. As operações
+// que escrevem leem com withFreshReads: localizar a linha a escrever (e as checagens de
+// concorrência) sempre olha a planilha atual.
+type CachedRepository struct {
+	next       SheetRepository
+	ttl        time.Duration
+	dependents map[string][]string
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	version uint64 // incrementado a cada invalidação
+	stats   models.CacheStats
+}
+
+type cacheEntry struct {
+	rows    [][]interface{}
+	expires time.Time
+}
+
+func NewCachedRepository(next SheetRepository, cfg config.Config) *CachedRepository {
+	dependents := make(map[string][]string)
+	for _, source := range []string{cfg.SheetHOM, cfg.SheetES, cfg.SheetREJ} {
+		if source != "" && cfg.SheetDIF != "" {
+			dependents[source] = append(dependents[source], cfg.SheetDIF)
+		}
+	}
+	return &CachedRepository{
+		next:       next,
+		ttl:        cfg.CacheTTL,
+		dependents: dependents,
+		now:        time.Now,
+		entries:    make(map[string]cacheEntry),
+		stats:      models.CacheStats{Enabled: true, TTLSeconds: cfg.CacheTTL.Seconds()},
+	}
+}
+
+type freshReadsKey struct{}
+
+// withFreshReads marca ctx para que o cache seja ignorado nas leituras (o resultado ainda
+// o realimenta).
+func withFreshReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshReadsKey{}, true)
+}
+
+func (c *CachedRepository) FetchRows(ctx context.Context, sheet string) ([][]interface{}, error) {
+	fresh, _ := ctx.Value(freshReadsKey{}).(bool)
+	c.mu.Lock()
+	if e, ok := c.entries[sheet]; ok && !fresh && c.now().Before(e.expires) {
+		c.stats.Hits++
+		c.mu.Unlock()
+		return copyRows(e.rows), nil
+	}
+	c.stats.Misses++
+	version := c.version
+	c.mu.Unlock()
+
+	rows, err := c.next.FetchRows(ctx, sheet)
+	if err != nil {
+		return nil, err
+	}
+
+	// Uma escrita durante a leitura pode ter deixado rows desatualizado: nesse caso ele
+	// serve só a este chamador e não entra no cache.
+	c.mu.Lock()
+	if c.version == version {
+		c.entries[sheet] = cacheEntry{rows: rows, expires: c.now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return copyRows(rows), nil
+}
+
+// FetchSheets serve do cache as abas válidas e baixa as demais numa única chamada.
+func (c *CachedRepository) FetchSheets(ctx context.Context, sheets ...string) (map[string][][]interface{}, error) {
+	fresh, _ := ctx.Value(freshReadsKey{}).(bool)
+	out := make(map[string][][]interface{}, len(sheets))
+	var missing []string
+
+	c.mu.Lock()
+	now := c.now()
+	for _, sheet := range sheets {
+		if e, ok := c.entries[sheet]; ok && !fresh && now.Before(e.expires) {
+			c.stats.Hits++
+			out[sheet] = copyRows(e.rows)
+			continue
+		}
+		c.stats.Misses++
+		missing = append(missing, sheet)
+	}
+	version := c.version
+	c.mu.Unlock()
+
+	if len(missing) == 0 {
+		return out, nil
+	}
+	fetched, err := c.next.FetchSheets(ctx, missing...)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	for _, sheet := range missing {
+		rows := fetched[sheet]
+		if c.version == version {
+			c.entries[sheet] = cacheEntry{rows: rows, expires: c.now().Add(c.ttl)}
+		}
+		out[sheet] = copyRows(rows)
+	}
+	c.mu.Unlock()
+	return out, nil
+}
+
+func (c *CachedRepository) WriteCell(ctx context.Context, sheet string, rowIdx, colIdx int, value interface{}) error {
+	defer c.invalidate(sheet)
+	return c.next.WriteCell(ctx, sheet, rowIdx, colIdx, value)
+}
+
+func (c *CachedRepository) WriteCells(ctx context.Context, sheet string, cells []models.CellUpdate) error {
+	defer c.invalidate(sheet)
+	return c.next.WriteCells(ctx, sheet, cells)
+}
+
+func (c *CachedRepository) AppendRow(ctx context.Context, sheet string, values []interface{}) error {
+	defer c.invalidate(sheet)
+	return c.next.AppendRow(ctx, sheet, values)
+}
+
+func (c *CachedRepository) AppendRows(ctx context.Context, sheet string, rows [][]interface{}) error {
+	defer c.invalidate(sheet)
+	return c.next.AppendRows(ctx, sheet, rows)
+}
+
+func (c *CachedRepository) DeleteRow(ctx context.Context, sheet string, rowIdx int) error {
+	defer c.invalidate(sheet)
+	return c.next.DeleteRow(ctx, sheet, rowIdx)
+}
+
+func (c *CachedRepository) DeleteRows(ctx context.Context, sheet string, rowIdxs []int) error {
+	defer c.invalidate(sheet)
+	return c.next.DeleteRows(ctx, sheet, rowIdxs)
+}
+
+// Stats devolve os contadores do cache desde a subida do processo.
+func (c *CachedRepository) Stats() models.CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Entries = len(c.entries)
+	return s
+}
+
+// invalidate descarta a aba e as que derivam dela. Roda mesmo se a escrita falhar: nesse
+// caso não se sabe o que chegou à planilha, e a próxima leitura vai buscar.
+func (c *CachedRepository) invalidate(sheet string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.version++
+	for _, s := range append([]string{sheet}, c.dependents[sheet]...) {
+		if _, ok := c.entries[s]; ok {
+			delete(c.entries, s)
+			c.stats.Invalidations++
+		}
+	}
+}
+
+// copyRows copia a lista de linhas para que um chamador que a reordene ou anexe nela não
+// altere o que está guardado. As células em si são compartilhadas.
+func copyRows(rows [][]interface{}) [][]interface{} {
+	if rows == nil {
+		return nil
+	}
+	out := make([][]interface{}, len(rows))
+	copy(out, rows)
+	return out
+}
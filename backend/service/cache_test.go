@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+// countingRepo conta quantas vezes cada aba foi baixada e quantas chamadas em lote houve.
+type countingRepo struct {
+	*memRepo
+	fetches map[string]int
+	batches int
+}
+
+func (c *countingRepo) FetchRows(ctx context.Context, sheet string) ([][]interface{}, error) {
+	c.fetches[sheet]++
+	return c.memRepo.FetchRows(ctx, sheet)
+}
+
+func (c *countingRepo) FetchSheets(ctx context.Context, sheets ...string) (map[string][][]interface{}, error) {
+	c.batches++
+	for _, s := range sheets {
+		c.fetches[s]++
+	}
+	return c.memRepo.FetchSheets(ctx, sheets...)
+}
+
+func newCachedTestRepo() (*CachedRepository, *countingRepo, *time.Time) {
+	header := testHeader
+	inner := &countingRepo{
+		memRepo: newMemRepo(map[string][][]interface{}{
+			"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+			"ES":  {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")},
+		}),
+		fetches: make(map[string]int),
+	}
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM", CacheTTL: 10 * time.Second}
+	cache := NewCachedRepository(inner, cfg)
+	now := time.Unix(0, 0)
+	cache.now = func() time.Time { return now }
+	return cache, inner, &now
+}
+
+func TestCachedRepository_ServesRepeatedReadsUntilTTL(t *testing.T) {
+	cache, inner, now := newCachedTestRepo()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.FetchRows(ctx, "DIF"); err != nil {
+			t.Fatalf("FetchRows() error: %v", err)
+		}
+	}
+	if inner.fetches["DIF"] != 1 {
+		t.Errorf("expected 1 download within the TTL, got %d", inner.fetches["DIF"])
+	}
+
+	*now = now.Add(11 * time.Second)
+	cache.FetchRows(ctx, "DIF")
+	if inner.fetches["DIF"] != 2 {
+		t.Errorf("expected a new download after the TTL, got %d", inner.fetches["DIF"])
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 || stats.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestCachedRepository_WriteToESInvalidatesESAndDIF(t *testing.T) {
+	cache, inner, _ := newCachedTestRepo()
+	ctx := context.Background()
+	cache.FetchRows(ctx, "DIF")
+	cache.FetchRows(ctx, "ES")
+
+	cache.WriteCells(ctx, "ES", []models.CellUpdate{{Row: 1, Col: models.ColumnIdParcela, Value: "p-1"}})
+	cache.FetchRows(ctx, "DIF")
+	cache.FetchRows(ctx, "ES")
+
+	if inner.fetches["DIF"] != 2 || inner.fetches["ES"] != 2 {
+		t.Errorf("expected DIF and ES downloaded again after the write, got %v", inner.fetches)
+	}
+	if cache.Stats().Invalidations != 2 {
+		t.Errorf("expected 2 invalidations, got %+v", cache.Stats())
+	}
+}
+
+func TestCachedRepository_WritePathsReadFresh(t *testing.T) {
+	cache, inner, _ := newCachedTestRepo()
+	logic := NewLogic(cache, config.Config{SheetDIF: "DIF", SheetES: "ES", CacheTTL: time.Minute})
+	ctx := context.Background()
+
+	if _, err := logic.GetConciliationDetails(ctx, 1); err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	if _, err := logic.GetConciliations(ctx); err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	if inner.fetches["DIF"] != 1 || inner.fetches["ES"] != 1 {
+		t.Fatalf("expected list+details to share one download per tab, got %v", inner.fetches)
+	}
+
+	if err := logic.Accept(ctx, 1, models.AcceptRequest{EsRowIndices: []int{1}}); err != nil {
+		t.Fatalf("Accept() error: %v", err)
+	}
+	if inner.fetches["DIF"] != 2 || inner.fetches["ES"] != 2 {
+		t.Errorf("expected Accept to bypass the cache, got %v", inner.fetches)
+	}
+}
+
+func TestCachedRepository_FetchSheetsDownloadsOnlyMissingTabs(t *testing.T) {
+	cache, inner, _ := newCachedTestRepo()
+	ctx := context.Background()
+	cache.FetchRows(ctx, "DIF")
+
+	got, err := cache.FetchSheets(ctx, "DIF", "ES")
+	if err != nil {
+		t.Fatalf("FetchSheets() error: %v", err)
+	}
+	if len(got["DIF"]) != 2 || len(got["ES"]) != 2 {
+		t.Fatalf("expected both tabs, got %v", got)
+	}
+	if inner.fetches["DIF"] != 1 || inner.fetches["ES"] != 1 || inner.batches != 1 {
+		t.Errorf("expected only ES downloaded in one batch, got %v in %d batches", inner.fetches, inner.batches)
+	}
+
+	cache.FetchSheets(ctx, "DIF", "ES")
+	if inner.batches != 1 {
+		t.Errorf("expected no download while both tabs are cached, got %d batches", inner.batches)
+	}
+}
+
+func TestGetConciliations_ReadsDIFAndESInOneBatch(t *testing.T) {
+	_, inner, _ := newCachedTestRepo()
+	logic := NewLogic(inner, config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM"})
+
+	if _, err := logic.GetConciliations(context.Background()); err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	if inner.batches != 1 || inner.fetches["DIF"] != 1 || inner.fetches["ES"] != 1 {
+		t.Errorf("expected DIF and ES in a single batch, got %v in %d batches", inner.fetches, inner.batches)
+	}
+}
@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"math"
+	"fmt"
+	"slices"
 	"strings"
 
 	"olivia-conciliation/backend/config"
@@ -17,6 +19,29 @@ var ErrTransactionNotInHOM = errors.New("transaction not found in HOM")
 // ErrEmptyIdParcela sinaliza um pedido de edição sem IdParcela. Mapeado para HTTP 400.
 var ErrEmptyIdParcela = errors.New("idParcela is required")
 
+// ErrCandidateMismatch sinaliza um Aceitar cuja linha da ES não casa com a DIF sob a
+// tolerância configurada (ex.: índice apontando para outra transação). Mapeado para HTTP 422.
+var ErrCandidateMismatch = errors.New("ES row does not match DIF transaction")
+
+// ErrEsRowNotPending sinaliza um Aceitar sobre uma linha da ES que já não é Transação
+// Pendente (ex.: aba desatualizada depois de alguém inserir linhas na ES). Mapeado para HTTP 409.
+var ErrEsRowNotPending = errors.New("ES row is not pending")
+
+// ErrStaleRow sinaliza uma ação por índice de linha cujo conteúdo mudou desde a listagem
+// (ex.: um Processamento de Transações recompactou a DIF no meio). Mapeado para HTTP 409.
+var ErrStaleRow = errors.New("row changed since it was listed")
+
+// ErrEmptySelection sinaliza um Aceitar sem nenhuma linha da ES. Mapeado para HTTP 422.
+var ErrEmptySelection = errors.New("no ES rows selected")
+
+// ErrIdParcelaNotInES sinaliza um desfazer de conciliação cujo IdParcela não está em
+// nenhuma linha da ES (já desfeito ou nunca aceito). Mapeado para HTTP 404.
+var ErrIdParcelaNotInES = errors.New("idParcela not linked to any ES row")
+
+// ErrIdParcelaNotInREJ sinaliza uma restauração cujo IdParcela não está em nenhuma
+// linha da REJ. Mapeado para HTTP 404.
+var ErrIdParcelaNotInREJ = errors.New("idParcela not found in REJ")
+
 type Logic struct {
 	repo   SheetRepository
 	cfg    config.Config
@@ -27,149 +52,498 @@ func NewLogic(repo SheetRepository, cfg config.Config) *Logic {
 	return &Logic{repo: repo, cfg: cfg}
 }
 
-func isMatch(dif, es models.Transaction) bool {
-	if dif.Dono != es.Dono || dif.Banco != es.Banco || dif.Conta != es.Conta {
-		return false
+// sheetData é uma aba lida do repositório, com as colunas localizadas pelo cabeçalho.
+type sheetData struct {
+	rows [][]interface{}
+	cols models.ColumnMap
+}
+
+// readSheet localiza as colunas de rows pelos cabeçalhos configurados para a aba name.
+func (l *Logic) readSheet(name string, rows [][]interface{}) (sheetData, error) {
+	var header []interface{}
+	if len(rows) > 0 {
+		header = rows[0]
 	}
-	return math.Abs(dif.Valor-es.Valor) < 5.00
+	cols, err := l.parser.Columns(l.cfg.HeadersFor(name), header)
+	if err != nil {
+		return sheetData{}, fmt.Errorf("tab %q: %w", name, err)
+	}
+	return sheetData{rows: rows, cols: cols}, nil
 }
 
-func (l *Logic) GetConciliations() ([]models.PendingConciliationSummary, error) {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+// fetchSheet baixa uma aba e localiza as colunas dela.
+func (l *Logic) fetchSheet(ctx context.Context, name string) (sheetData, error) {
+	rows, err := l.repo.FetchRows(ctx, name)
 	if err != nil {
-		return nil, err
+		return sheetData{}, err
 	}
-	esRows, err := l.repo.FetchRows(l.cfg.SheetES)
+	return l.readSheet(name, rows)
+}
+
+// fetchSheets baixa as abas numa única chamada ao repositório e as devolve na ordem pedida.
+func (l *Logic) fetchSheets(ctx context.Context, names ...string) ([]sheetData, error) {
+	fetched, err := l.repo.FetchSheets(ctx, names...)
 	if err != nil {
 		return nil, err
 	}
+	sheets := make([]sheetData, len(names))
+	for i, name := range names {
+		if sheets[i], err = l.readSheet(name, fetched[name]); err != nil {
+			return nil, err
+		}
+	}
+	return sheets, nil
+}
+
+// fetchDIFAndES baixa DIF e ES numa única chamada ao repositório.
+func (l *Logic) fetchDIFAndES(ctx context.Context) (difSheet, esSheet sheetData, err error) {
+	sheets, err := l.fetchSheets(ctx, l.cfg.SheetDIF, l.cfg.SheetES)
+	if err != nil {
+		return sheetData{}, sheetData{}, err
+	}
+	return sheets[0], sheets[1], nil
+}
+
+// parse lê a linha i de s. Com StrictParsing, uma linha com células ilegíveis devolve
+// *RowError em vez de uma transação com zeros no lugar delas.
+func (l *Logic) parse(s sheetData, i int, label string) (models.Transaction, error) {
+	if l.cfg.StrictParsing {
+		return l.parser.ParseTransactionStrict(s.cols, i, s.rows[i], label)
+	}
+	return l.parser.ParseTransaction(s.cols, i, s.rows[i], label), nil
+}
 
-	var candidates []models.Transaction
-	for i := 1; i < len(esRows); i++ {
-		t := l.parser.ParseTransaction(i, esRows[i], "ES")
-		if l.parser.IsPending(t) {
-			candidates = append(candidates, t)
+// remapRow leva uma linha do layout from para o layout to. Com o mesmo layout a linha vai
+// inteira; senão só os campos da transação são copiados, cada um para a sua coluna.
+func remapRow(row []interface{}, from, to models.ColumnMap) []interface{} {
+	if from == to {
+		return row
+	}
+	src, dst := from.Fields(), to.Fields()
+	out := make([]interface{}, slices.Max(dst)+1)
+	for i := range out {
+		out[i] = ""
+	}
+	for i := range dst {
+		if src[i] < len(row) {
+			out[dst[i]] = row[src[i]]
 		}
 	}
+	return out
+}
 
-	var results []models.PendingConciliationSummary
-	for i := 1; i < len(difRows); i++ {
-		dif := l.parser.ParseTransaction(i, difRows[i], "DIF")
-		if dif.Dono == "" && dif.Valor == 0 {
+// outgoingRow prepara uma linha da DIF para ser anexada em outra aba: remapeia para o
+// layout de destino sem tocar na linha do cache e troca o Valor legível por models.Money,
+// para o Sheets gravá-lo como número e não como texto.
+func (l *Logic) outgoingRow(row []interface{}, from, to models.ColumnMap) []interface{} {
+	out := remapRow(row, from, to)
+	if from == to {
+		out = slices.Clone(out)
+	}
+	if to.Valor < len(out) {
+		if m, ok := l.parser.parseAmount(out[to.Valor]); ok {
+			out[to.Valor] = m
+		}
+	}
+	return out
+}
+
+// CacheStats devolve os contadores do cache de leitura, se o repositório tiver um.
+func (l *Logic) CacheStats() models.CacheStats {
+	if c, ok := l.repo.(*CachedRepository); ok {
+		return c.Stats()
+	}
+	return models.CacheStats{}
+}
+
+func isMatch(dif, es models.Transaction, tol models.AppliedTolerance) bool {
+	if dif.Dono != es.Dono || dif.Banco != es.Banco || dif.Conta != es.Conta {
+		return false
+	}
+	if !sameInstallment(dif, es) {
+		return false
+	}
+	return (dif.Valor - es.Valor).Abs() < tol.Limit
+}
+
+// sameInstallment só reprova quando os dois lados têm parcela identificada e ela
+// difere: a parcela 3 não pode ocupar a linha da ES reservada para a parcela 4.
+// Se um dos lados não traz "N/M" na Descrição, decidem Valor e pontuação.
+func sameInstallment(dif, es models.Transaction) bool {
+	if dif.Parcela == 0 || es.Parcela == 0 {
+		return true
+	}
+	if dif.Parcela != es.Parcela {
+		return false
+	}
+	return dif.TotalParcelas == es.TotalParcelas
+}
+
+// pendingES devolve as Transações Pendentes da ES, na ordem da planilha.
+func (l *Logic) pendingES(es sheetData) []models.Transaction {
+	var pending []models.Transaction
+	for i := 1; i < len(es.rows); i++ {
+		t, err := l.parse(es, i, "ES")
+		if err == nil && l.parser.IsPending(t) {
+			pending = append(pending, t)
+		}
+	}
+	return pending
+}
+
+// recurringDIF devolve as Transações Parceladas da DIF, pulando linhas vazias.
+func (l *Logic) recurringDIF(difSheet sheetData) []models.Transaction {
+	var recurring []models.Transaction
+	for i := 1; i < len(difSheet.rows); i++ {
+		dif, err := l.parse(difSheet, i, "DIF")
+		if err != nil || dif.Dono == "" && dif.Valor == 0 {
 			continue
 		}
 		if !dif.Recorrente {
 			continue
 		}
+		recurring = append(recurring, dif)
+	}
+	return recurring
+}
 
-		count := 0
-		for _, es := range candidates {
-			if isMatch(dif, es) {
-				count++
-			}
+// rankCandidates filtra as Candidatas de dif entre as pendentes e as ordena pela
+// pontuação. As que caem fora da janela de datas vêm marcadas e por último; quem só
+// quer as elegíveis usa inWindowOnly.
+func (l *Logic) rankCandidates(dif models.Transaction, pending []models.Transaction) ([]models.ScoredCandidate, models.AppliedTolerance) {
+	tol := l.toleranceFor(dif)
+	var ranked []models.ScoredCandidate
+	for _, es := range pending {
+		if isMatch(dif, es, tol) {
+			sc := l.scoreCandidate(dif, es, tol)
+			sc.OutOfWindow = !l.inDateWindow(dif, es)
+			ranked = append(ranked, sc)
+		}
+	}
+	sortByScore(ranked)
+	return ranked, tol
+}
+
+// inDateWindow informa se as Datas da DIF e da ES distam no máximo MatchDateWindowDays.
+// Sem janela configurada ou sem Data interpretável de um dos lados, não restringe:
+// uma Data ilegível não deve esconder a Candidata.
+func (l *Logic) inDateWindow(dif, es models.Transaction) bool {
+	if l.cfg.MatchDateWindowDays <= 0 || !dif.Data.Valid() || !es.Data.Valid() {
+		return true
+	}
+	return models.DaysBetween(dif.Data, es.Data) <= l.cfg.MatchDateWindowDays
+}
+
+// inWindowOnly descarta as Candidatas marcadas como fora da janela de datas.
+func inWindowOnly(ranked []models.ScoredCandidate) []models.ScoredCandidate {
+	var kept []models.ScoredCandidate
+	for _, c := range ranked {
+		if !c.OutOfWindow {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+func (l *Logic) GetConciliations(ctx context.Context) ([]models.PendingConciliationSummary, error) {
+	difSheet, esSheet, err := l.fetchDIFAndES(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := l.pendingES(esSheet)
+	recurring := l.recurringDIF(difSheet)
+	a := l.assign(recurring, pending)
+
+	var results []models.PendingConciliationSummary
+	for _, dif := range recurring {
+		ranked, _ := l.rankCandidates(dif, pending)
+		ranked = inWindowOnly(ranked)
+		topScore := 0.0
+		if len(ranked) > 0 {
+			topScore = ranked[0].Score
+		}
+		contested := false
+		for _, c := range ranked {
+			contested = contested || a.contested(c.RowIndex)
 		}
 
 		results = append(results, models.PendingConciliationSummary{
-			DifRowIndex:    dif.RowIndex,
-			IdParcela:      dif.IdParcela,
-			Dono:           dif.Dono,
-			Banco:          dif.Banco,
-			Conta:          dif.Conta,
-			Descricao:      dif.Descricao,
-			Data:           dif.Data,
-			Valor:          dif.Valor,
-			CandidateCount: count,
+			DifRowIndex:        dif.RowIndex,
+			IdParcela:          dif.IdParcela,
+			Dono:               dif.Dono,
+			Banco:              dif.Banco,
+			Conta:              dif.Conta,
+			Descricao:          dif.Descricao,
+			Data:               dif.Data,
+			Valor:              dif.Valor,
+			Parcela:            dif.Parcela,
+			TotalParcelas:      dif.TotalParcelas,
+			CandidateCount:     len(ranked),
+			TopScore:           topScore,
+			ProposedEsRowIndex: a.byDif[dif.RowIndex].RowIndex,
+			Contested:          contested,
 		})
 	}
 	return results, nil
 }
 
-func (l *Logic) GetConciliationDetails(difIndex int) (*models.ConciliationCandidate, error) {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) GetConciliationDetails(ctx context.Context, difIndex int) (*models.ConciliationCandidate, error) {
+	difSheet, esSheet, err := l.fetchDIFAndES(ctx)
 	if err != nil {
 		return nil, err
 	}
-	if difIndex >= len(difRows) {
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
 		return nil, errors.New("DIF index out of bounds")
 	}
 
-	dif := l.parser.ParseTransaction(difIndex, difRows[difIndex], "DIF")
-	if !dif.Recorrente {
-		return nil, errors.New("DIF transaction is not recurring")
-	}
-
-	esRows, err := l.repo.FetchRows(l.cfg.SheetES)
+	dif, err := l.parse(difSheet, difIndex, "DIF")
 	if err != nil {
 		return nil, err
 	}
+	if !dif.Recorrente {
+		return nil, errors.New("DIF transaction is not recurring")
+	}
 
-	var matchCandidates []models.Transaction
-	for i := 1; i < len(esRows); i++ {
-		t := l.parser.ParseTransaction(i, esRows[i], "ES")
-		if l.parser.IsPending(t) && isMatch(dif, t) {
-			matchCandidates = append(matchCandidates, t)
+	pending := l.pendingES(esSheet)
+	recurring := l.recurringDIF(difSheet)
+	ranked, tol := l.rankCandidates(dif, pending)
+	a := l.assign(recurring, pending)
+	for i := range ranked {
+		for _, other := range a.claims[ranked[i].RowIndex] {
+			if other != dif.RowIndex {
+				ranked[i].ClaimedBy = append(ranked[i].ClaimedBy, other)
+			}
 		}
 	}
 
 	return &models.ConciliationCandidate{
-		Reference:  dif,
-		Candidates: matchCandidates,
+		Reference:          dif,
+		Candidates:         ranked,
+		Tolerance:          tol,
+		Groups:             l.findGroups(dif, recurring, pending, tol),
+		DateWindowDays:     l.cfg.MatchDateWindowDays,
+		ProposedEsRowIndex: a.byDif[dif.RowIndex].RowIndex,
 	}, nil
 }
 
-func (l *Logic) Accept(difIndex int, esIndices []int) error {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) Accept(ctx context.Context, difIndex int, req models.AcceptRequest) error {
+	ctx = withFreshReads(ctx)
+	difSheet, esSheet, err := l.fetchDIFAndES(ctx)
 	if err != nil {
 		return err
 	}
-	if difIndex >= len(difRows) {
-		return errors.New("index out of bounds")
+	cells, entries, err := l.acceptChanges(difSheet, esSheet, difIndex, req)
+	if err != nil {
+		return err
 	}
 
-	dif := l.parser.ParseTransaction(difIndex, difRows[difIndex], "DIF")
+	// Um split grava várias linhas da ES: numa chamada só, para não deixar a conciliação
+	// pela metade se a API falhar no meio.
+	if err := l.repo.WriteCells(ctx, l.cfg.SheetES, cells); err != nil {
+		return err
+	}
+	l.audit(ctx, entries...)
+	return nil
+}
+
+// acceptChanges valida um Aceitar contra as abas já lidas e devolve as células da ES a
+// gravar e as entradas de auditoria correspondentes, sem escrever nada.
+func (l *Logic) acceptChanges(difSheet, esSheet sheetData, difIndex int, req models.AcceptRequest) ([]models.CellUpdate, []auditEntry, error) {
+	if err := checkDifRow(difSheet, difIndex, req.IdParcela); err != nil {
+		return nil, nil, err
+	}
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
+		return nil, nil, errors.New("index out of bounds")
+	}
+
+	dif, err := l.parse(difSheet, difIndex, "DIF")
+	if err != nil {
+		return nil, nil, err
+	}
 	if dif.IdParcela == "" {
-		return errors.New("DIF transaction has no ID")
+		return nil, nil, errors.New("DIF transaction has no ID")
+	}
+
+	if len(req.EsRowIndices) == 0 {
+		return nil, nil, ErrEmptySelection
 	}
 
-	for _, esIdx := range esIndices {
-		if err := l.repo.WriteCell(l.cfg.SheetES, esIdx, models.ColumnIdParcela, dif.IdParcela); err != nil {
-			return err
+	selected, err := l.validateSelection(dif, esSheet, req.EsRowIndices, req.ExpectedEsRows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cells := make([]models.CellUpdate, len(selected))
+	entries := make([]auditEntry, len(selected))
+	for i, es := range selected {
+		cells[i] = models.CellUpdate{Row: es.RowIndex, Col: esSheet.cols.IdParcela, Value: dif.IdParcela}
+		entries[i] = auditEntry{
+			action:    AuditActionAccept,
+			idParcela: dif.IdParcela,
+			sheet:     l.cfg.SheetES,
+			row:       es.RowIndex,
+			before:    es.IdParcela,
+			after:     dif.IdParcela,
 		}
 	}
+	return cells, entries, nil
+}
+
+// Unlink desfaz o Aceitar: limpa o IdParcela de toda linha da ES que o carrega. Se o
+// Aceitar tinha sobrescrito uma Parcela Sintética, o IdParcela sintético anterior
+// (lido da auditoria) é restaurado, e a linha volta a ser Transação Pendente. Em ambos
+// os casos a parcela reaparece na DIF no próximo recálculo da fórmula.
+func (l *Logic) Unlink(ctx context.Context, idParcela string) (*models.UnlinkResult, error) {
+	ctx = withFreshReads(ctx)
+	target := strings.TrimSpace(idParcela)
+	if target == "" {
+		return nil, ErrEmptyIdParcela
+	}
+
+	tabs := []string{l.cfg.SheetES}
+	if l.cfg.SheetAUD != "" {
+		tabs = append(tabs, l.cfg.SheetAUD)
+	}
+	fetched, err := l.repo.FetchSheets(ctx, tabs...)
+	if err != nil {
+		return nil, err
+	}
+	esSheet, err := l.readSheet(l.cfg.SheetES, fetched[l.cfg.SheetES])
+	if err != nil {
+		return nil, err
+	}
+	audRows := fetched[l.cfg.SheetAUD]
+
+	result := &models.UnlinkResult{IdParcela: target, Rows: make([]models.UnlinkedRow, 0)}
+	var cells []models.CellUpdate
+	var entries []auditEntry
+	for i := 1; i < len(esSheet.rows); i++ {
+		es := l.parser.ParseTransaction(esSheet.cols, i, esSheet.rows[i], "ES")
+		if strings.TrimSpace(es.IdParcela) != target {
+			continue
+		}
+
+		restored := l.previousIdParcela(audRows, target, i)
+		if !strings.HasPrefix(strings.ToLower(restored), "synthetic") {
+			restored = ""
+		}
+
+		cells = append(cells, models.CellUpdate{Row: i, Col: esSheet.cols.IdParcela, Value: restored})
+		entries = append(entries, auditEntry{
+			action:    AuditActionUnlink,
+			idParcela: target,
+			sheet:     l.cfg.SheetES,
+			row:       i,
+			before:    es.IdParcela,
+			after:     restored,
+		})
+		result.Rows = append(result.Rows, models.UnlinkedRow{EsRowIndex: i, RestoredIdParcela: restored})
+	}
+
+	if len(result.Rows) == 0 {
+		return nil, ErrIdParcelaNotInES
+	}
+	if err := l.repo.WriteCells(ctx, l.cfg.SheetES, cells); err != nil {
+		return nil, err
+	}
+	l.audit(ctx, entries...)
+	return result, nil
+}
+
+// checkDifRow confere, quando o cliente informa, que a linha da DIF ainda carrega o
+// IdParcela que ele viu ao listar. Um Processamento de Transações no meio reescreve a HOM
+// e a DIF se recompacta; sem a checagem a ação cairia em outra transação (ADR 0004).
+func checkDifRow(difSheet sheetData, difIndex int, expected string) error {
+	expected = strings.TrimSpace(expected)
+	if expected == "" {
+		return nil
+	}
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
+		return fmt.Errorf("%w: DIF row %d no longer exists", ErrStaleRow, difIndex)
+	}
+	if got := strings.TrimSpace(cellString(difSheet.rows[difIndex], difSheet.cols.IdParcela)); got != expected {
+		return fmt.Errorf("%w: DIF row %d now carries IdParcela %q, expected %q", ErrStaleRow, difIndex, got, expected)
+	}
 	return nil
 }
 
-func (l *Logic) Reject(difIndex int) error {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) Reject(ctx context.Context, difIndex int, idParcela string) error {
+	ctx = withFreshReads(ctx)
+	sheets, err := l.fetchSheets(ctx, l.cfg.SheetDIF, l.cfg.SheetREJ)
 	if err != nil {
 		return err
 	}
-	if difIndex >= len(difRows) {
+	difSheet, rejSheet := sheets[0], sheets[1]
+	if err := checkDifRow(difSheet, difIndex, idParcela); err != nil {
+		return err
+	}
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
 		return errors.New("index out of bounds")
 	}
 
-	rowContent := difRows[difIndex]
+	rowContent := l.outgoingRow(difSheet.rows[difIndex], difSheet.cols, rejSheet.cols)
 	// A DIF é gerada por fórmula FILTER sobre a HOM; ao anexar na REJ, a fórmula
 	// remove a linha da DIF sozinha no próximo recálculo. Limpar a DIF aqui é
 	// redundante e ineficaz (células de spill são read-only). Ver #41/#23.
-	return l.repo.AppendRow(l.cfg.SheetREJ, rowContent)
+	return l.repo.AppendRow(ctx, l.cfg.SheetREJ, rowContent)
 }
 
-func (l *Logic) ListNonRecurringDIF() ([]models.NonRecurringDifSummary, error) {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+// RestoreRejected desfaz um Rejeitar: remove da REJ as linhas com o IdParcela pedido.
+// Como a fórmula da DIF exclui o que está na REJ, a transação volta à DIF no próximo
+// recálculo da planilha (desde que continue na HOM).
+func (l *Logic) RestoreRejected(ctx context.Context, idParcela string) error {
+	ctx = withFreshReads(ctx)
+	target := strings.TrimSpace(idParcela)
+	if target == "" {
+		return ErrEmptyIdParcela
+	}
+
+	rejSheet, err := l.fetchSheet(ctx, l.cfg.SheetREJ)
+	if err != nil {
+		return err
+	}
+
+	var found []int
+	for i := 1; i < len(rejSheet.rows); i++ {
+		if strings.TrimSpace(cellString(rejSheet.rows[i], rejSheet.cols.IdParcela)) == target {
+			found = append(found, i)
+		}
+	}
+	if len(found) == 0 {
+		return ErrIdParcelaNotInREJ
+	}
+
+	// Uma só chamada: ou todas as linhas saem da REJ, ou nenhuma, e a auditoria registra
+	// a restauração inteira numa entrada.
+	if err := l.repo.DeleteRows(ctx, l.cfg.SheetREJ, found); err != nil {
+		return err
+	}
+	l.audit(ctx, auditEntry{
+		action:    AuditActionRestore,
+		idParcela: target,
+		sheet:     l.cfg.SheetREJ,
+		rows:      found,
+		before:    target,
+	})
+	return nil
+}
+
+func (l *Logic) ListNonRecurringDIF(ctx context.Context) ([]models.NonRecurringDifSummary, error) {
+	difSheet, err := l.fetchSheet(ctx, l.cfg.SheetDIF)
 	if err != nil {
 		return nil, err
 	}
 
 	results := make([]models.NonRecurringDifSummary, 0)
-	for i := 1; i < len(difRows); i++ {
-		row := difRows[i]
+	for i := 1; i < len(difSheet.rows); i++ {
+		row := difSheet.rows[i]
 		if l.parser.IsEmpty(row) {
 			continue
 		}
 
-		dif := l.parser.ParseTransaction(i, row, "DIF")
-		if dif.Recorrente {
+		dif, err := l.parse(difSheet, i, "DIF")
+		if err != nil || dif.Recorrente {
 			continue
 		}
 
@@ -189,125 +563,157 @@ func (l *Logic) ListNonRecurringDIF() ([]models.NonRecurringDifSummary, error) {
 	return results, nil
 }
 
-func (l *Logic) MoveNonRecurringDifToES(difIndex int) error {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) MoveNonRecurringDifToES(ctx context.Context, difIndex int, idParcela string) error {
+	ctx = withFreshReads(ctx)
+	sheets, err := l.fetchSheets(ctx, l.cfg.SheetDIF, l.cfg.SheetES)
 	if err != nil {
 		return err
 	}
-	if difIndex >= len(difRows) {
+	difSheet, target := sheets[0], sheets[1]
+	if err := checkDifRow(difSheet, difIndex, idParcela); err != nil {
+		return err
+	}
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
 		return errors.New("index out of bounds")
 	}
 
-	rowContent := difRows[difIndex]
+	rowContent := difSheet.rows[difIndex]
 	if l.parser.IsEmpty(rowContent) {
 		return errors.New("DIF row is empty")
 	}
 
-	dif := l.parser.ParseTransaction(difIndex, rowContent, "DIF")
+	dif, err := l.parse(difSheet, difIndex, "DIF")
+	if err != nil {
+		return err
+	}
 	if dif.Recorrente {
 		return errors.New("DIF transaction is recurring")
 	}
+	rowContent = l.outgoingRow(rowContent, difSheet.cols, target.cols)
 
 	// A fórmula da DIF remove a linha sozinha após o AppendRow na ES; limpar a
 	// DIF aqui seria redundante e ineficaz (spill read-only). Ver #41/#23.
-	return l.repo.AppendRow(l.cfg.SheetES, rowContent)
+	return l.repo.AppendRow(ctx, l.cfg.SheetES, rowContent)
 }
 
-func (l *Logic) MoveNonRecurringDifToREJ(difIndex int) error {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) MoveNonRecurringDifToREJ(ctx context.Context, difIndex int, idParcela string) error {
+	ctx = withFreshReads(ctx)
+	sheets, err := l.fetchSheets(ctx, l.cfg.SheetDIF, l.cfg.SheetREJ)
 	if err != nil {
 		return err
 	}
-	if difIndex >= len(difRows) {
+	difSheet, target := sheets[0], sheets[1]
+	if err := checkDifRow(difSheet, difIndex, idParcela); err != nil {
+		return err
+	}
+	if difIndex < 1 || difIndex >= len(difSheet.rows) {
 		return errors.New("index out of bounds")
 	}
 
-	rowContent := difRows[difIndex]
+	rowContent := difSheet.rows[difIndex]
 	if l.parser.IsEmpty(rowContent) {
 		return errors.New("DIF row is empty")
 	}
 
-	dif := l.parser.ParseTransaction(difIndex, rowContent, "DIF")
+	dif, err := l.parse(difSheet, difIndex, "DIF")
+	if err != nil {
+		return err
+	}
 	if dif.Recorrente {
 		return errors.New("DIF transaction is recurring")
 	}
+	rowContent = l.outgoingRow(rowContent, difSheet.cols, target.cols)
 
 	// A fórmula da DIF remove a linha sozinha após o AppendRow na REJ; limpar a
 	// DIF aqui seria redundante e ineficaz (spill read-only). Ver #41/#23.
-	return l.repo.AppendRow(l.cfg.SheetREJ, rowContent)
+	return l.repo.AppendRow(ctx, l.cfg.SheetREJ, rowContent)
 }
 
-func (l *Logic) MoveAllNonRecurringDifToES() (*models.NonRecurringBulkActionResult, error) {
-	difRows, err := l.repo.FetchRows(l.cfg.SheetDIF)
+func (l *Logic) MoveAllNonRecurringDifToES(ctx context.Context) (*models.NonRecurringBulkActionResult, error) {
+	ctx = withFreshReads(ctx)
+	sheets, err := l.fetchSheets(ctx, l.cfg.SheetDIF, l.cfg.SheetES)
 	if err != nil {
 		return nil, err
 	}
+	difSheet, esSheet := sheets[0], sheets[1]
 
-	moved := 0
-	for i := 1; i < len(difRows); i++ {
-		rowContent := difRows[i]
+	var rows [][]interface{}
+	for i := 1; i < len(difSheet.rows); i++ {
+		rowContent := difSheet.rows[i]
 		if l.parser.IsEmpty(rowContent) {
 			continue
 		}
 
-		dif := l.parser.ParseTransaction(i, rowContent, "DIF")
-		if dif.Recorrente {
+		dif, err := l.parse(difSheet, i, "DIF")
+		if err != nil || dif.Recorrente {
 			continue
 		}
 
-		// A fórmula da DIF remove cada linha sozinha após o AppendRow na ES;
-		// limpar a DIF aqui seria redundante e ineficaz. Ver #41/#23.
-		if err := l.repo.AppendRow(l.cfg.SheetES, rowContent); err != nil {
-			return nil, err
-		}
-		moved++
+		rows = append(rows, l.outgoingRow(rowContent, difSheet.cols, esSheet.cols))
+	}
+
+	// Um único AppendCells: com dezenas de linhas, uma chamada por linha estoura a cota do
+	// Sheets e, se falhar no meio, deixa a ES meio populada. A fórmula da DIF remove as
+	// linhas sozinha após o append; limpar a DIF aqui seria redundante e ineficaz. Ver #41/#23.
+	if err := l.repo.AppendRows(ctx, l.cfg.SheetES, rows); err != nil {
+		return nil, err
 	}
 
-	return &models.NonRecurringBulkActionResult{MovedToES: moved}, nil
+	return &models.NonRecurringBulkActionResult{MovedToES: len(rows)}, nil
 }
 
 // findHOMRowByIdParcela localiza na HOM a linha cujo IdParcela é igual ao pedido.
 // Como o IdParcela é único (ver CONTEXT.md), retorna no máximo uma linha.
 // Endereçar por identidade — e não pelo índice da DIF — evita o descasamento do #21:
 // a DIF é gerada por FILTER sobre a HOM, então os índices raramente coincidem.
-func (l *Logic) findHOMRowByIdParcela(idParcela string) (int, error) {
+// Devolve também as colunas da HOM, para quem vai escrever na linha encontrada.
+func (l *Logic) findHOMRowByIdParcela(ctx context.Context, idParcela string) (int, models.ColumnMap, error) {
 	target := strings.TrimSpace(idParcela)
 	if target == "" {
-		return 0, ErrEmptyIdParcela
+		return 0, models.ColumnMap{}, ErrEmptyIdParcela
 	}
 
-	homRows, err := l.repo.FetchRows(l.cfg.SheetHOM)
+	homSheet, err := l.fetchSheet(ctx, l.cfg.SheetHOM)
 	if err != nil {
-		return 0, err
+		return 0, models.ColumnMap{}, err
 	}
 
-	for i := 1; i < len(homRows); i++ {
-		row := homRows[i]
+	for i := 1; i < len(homSheet.rows); i++ {
+		row := homSheet.rows[i]
 		if l.parser.IsEmpty(row) {
 			continue
 		}
-		hom := l.parser.ParseTransaction(i, row, "HOM")
+		hom := l.parser.ParseTransaction(homSheet.cols, i, row, "HOM")
 		if strings.TrimSpace(hom.IdParcela) == target {
-			return i, nil
+			return i, homSheet.cols, nil
 		}
 	}
-	return 0, ErrTransactionNotInHOM
+	return 0, homSheet.cols, ErrTransactionNotInHOM
 }
 
-// updateHOMFieldByIdParcela localiza a linha da HOM pelo IdParcela e escreve value
-// na coluna col. Base comum de UpdateDifCategory/UpdateDifDate, que só diferem na coluna.
-func (l *Logic) updateHOMFieldByIdParcela(idParcela string, col int, value string) error {
-	rowIdx, err := l.findHOMRowByIdParcela(idParcela)
+// updateHOMFieldByIdParcela localiza a linha da HOM pelo IdParcela e escreve value na
+// coluna que field escolhe. Base comum de UpdateDifCategory/UpdateDifDate, que só
+// diferem na coluna e no tipo do valor.
+func (l *Logic) updateHOMFieldByIdParcela(ctx context.Context, idParcela string, field func(models.ColumnMap) int, value interface{}) error {
+	ctx = withFreshReads(ctx)
+	rowIdx, cols, err := l.findHOMRowByIdParcela(ctx, idParcela)
 	if err != nil {
 		return err
 	}
-	return l.repo.WriteCell(l.cfg.SheetHOM, rowIdx, col, value)
+	return l.repo.WriteCell(ctx, l.cfg.SheetHOM, rowIdx, field(cols), value)
 }
 
-func (l *Logic) UpdateDifCategory(idParcela, categoria string) error {
-	return l.updateHOMFieldByIdParcela(idParcela, models.ColumnCategoria, categoria)
+func (l *Logic) UpdateDifCategory(ctx context.Context, idParcela, categoria string) error {
+	return l.updateHOMFieldByIdParcela(ctx, idParcela, func(c models.ColumnMap) int { return c.Categoria }, categoria)
 }
 
-func (l *Logic) UpdateDifDate(idParcela, data string) error {
-	return l.updateHOMFieldByIdParcela(idParcela, models.ColumnData, data)
+// UpdateDifDate grava a Data como data de verdade (models.Date), não como o texto que o
+// cliente mandou: um texto na coluna deixaria a fórmula da DIF e as contas de data da
+// planilha sem ter o que comparar. Data ilegível devolve ErrInvalidDate.
+func (l *Logic) UpdateDifDate(ctx context.Context, idParcela, data string) error {
+	date, err := l.parser.parseDateInput(data)
+	if err != nil {
+		return err
+	}
+	return l.updateHOMFieldByIdParcela(ctx, idParcela, func(c models.ColumnMap) int { return c.Data }, date)
 }
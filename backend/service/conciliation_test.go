@@ -1,8 +1,10 @@
 package service
 
 import (
+	"context"
 	"errors"
-	"math"
+	"slices"
+	"strings"
 	"testing"
 
 	"olivia-conciliation/backend/config"
@@ -15,41 +17,89 @@ type memRepo struct {
 	sheets   map[string][][]interface{}
 	appended map[string][][]interface{}
 	written  []writtenCell
+	deleted  map[string][]int
+	calls    int // chamadas de escrita (WriteCell/WriteCells/AppendRow/AppendRows)
+	fetches  int // chamadas de leitura (FetchRows/FetchSheets)
 }
 
 type writtenCell struct {
 	sheet string
 	row   int
 	col   int
-	value string
+	value interface{}
 }
 
 func newMemRepo(sheets map[string][][]interface{}) *memRepo {
 	return &memRepo{
 		sheets:   sheets,
 		appended: make(map[string][][]interface{}),
+		deleted:  make(map[string][]int),
 	}
 }
 
-func (m *memRepo) FetchRows(sheet string) ([][]interface{}, error) {
+func (m *memRepo) FetchRows(_ context.Context, sheet string) ([][]interface{}, error) {
+	m.fetches++
 	return m.sheets[sheet], nil
 }
 
-func (m *memRepo) WriteCell(sheet string, rowIdx, colIdx int, value string) error {
+func (m *memRepo) FetchSheets(_ context.Context, sheets ...string) (map[string][][]interface{}, error) {
+	m.fetches++
+	out := make(map[string][][]interface{}, len(sheets))
+	for _, s := range sheets {
+		out[s] = m.sheets[s]
+	}
+	return out, nil
+}
+
+func (m *memRepo) WriteCell(_ context.Context, sheet string, rowIdx, colIdx int, value interface{}) error {
+	m.calls++
 	m.written = append(m.written, writtenCell{sheet, rowIdx, colIdx, value})
 	return nil
 }
 
-func (m *memRepo) AppendRow(sheet string, values []interface{}) error {
+func (m *memRepo) WriteCells(_ context.Context, sheet string, cells []models.CellUpdate) error {
+	m.calls++
+	for _, c := range cells {
+		m.written = append(m.written, writtenCell{sheet, c.Row, c.Col, c.Value})
+	}
+	return nil
+}
+
+func (m *memRepo) AppendRow(_ context.Context, sheet string, values []interface{}) error {
+	m.calls++
 	m.appended[sheet] = append(m.appended[sheet], values)
 	return nil
 }
 
+func (m *memRepo) AppendRows(_ context.Context, sheet string, rows [][]interface{}) error {
+	m.calls++
+	m.appended[sheet] = append(m.appended[sheet], rows...)
+	return nil
+}
+
+func (m *memRepo) DeleteRow(_ context.Context, sheet string, rowIdx int) error {
+	return m.DeleteRows(context.Background(), sheet, []int{rowIdx})
+}
+
+func (m *memRepo) DeleteRows(_ context.Context, sheet string, rowIdxs []int) error {
+	m.calls++
+	m.deleted[sheet] = append(m.deleted[sheet], rowIdxs...)
+	return nil
+}
+
 // --- Parser tests ---
 
 var p Parser
 
-func TestParseFloat(t *testing.T) {
+// testHeader é o cabeçalho das abas de transações no layout padrão.
+var testHeader = []interface{}{"Id", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente", "IdParcela"}
+
+// reais escreve um Valor de teste em reais.
+func reais(v float64) models.Money {
+	return models.MoneyFromFloat(v)
+}
+
+func TestParseMoney(t *testing.T) {
 	cases := []struct {
 		input    interface{}
 		expected float64
@@ -67,9 +117,8 @@ func TestParseFloat(t *testing.T) {
 	}
 
 	for _, c := range cases {
-		got := p.parseFloat(c.input)
-		if math.Abs(got-c.expected) > 0.001 {
-			t.Errorf("parseFloat(%v) = %v, want %v", c.input, got, c.expected)
+		if got := p.parseMoney(c.input); got != reais(c.expected) {
+			t.Errorf("parseMoney(%v) = %v, want %v", c.input, got, c.expected)
 		}
 	}
 }
@@ -166,8 +215,60 @@ func TestIsEmpty(t *testing.T) {
 	}
 }
 
+func TestParseDate(t *testing.T) {
+	cases := []struct {
+		input  interface{}
+		want   string
+		wantOk bool
+	}{
+		{"14/06/2026", "2026-06-14", true},
+		{"4/6/2026", "2026-06-04", true},
+		{"2026-06-14", "2026-06-14", true},
+		{" 14/06/2026 ", "2026-06-14", true},
+		{float64(46187), "2026-06-14", true},
+		{46187.75, "2026-06-14", true},
+		{"46187", "2026-06-14", true},
+		{float64(0), "", false},
+		{"-3", "", false},
+		{"", "", false},
+		{nil, "", false},
+		{"ontem", "", false},
+	}
+
+	for _, c := range cases {
+		got, ok := p.parseDate(c.input)
+		if ok != c.wantOk || (ok && got.Format("2006-01-02") != c.want) {
+			t.Errorf("parseDate(%v) = %v, %v; want %s, %v", c.input, got, ok, c.want, c.wantOk)
+		}
+	}
+}
+
+func TestParseInstallment(t *testing.T) {
+	cases := []struct {
+		input     string
+		wantIndex int
+		wantTotal int
+	}{
+		{"NETFLIX PARC 03/10", 3, 10},
+		{"Loja X 3/10", 3, 10},
+		{"Parcela 1 / 12", 1, 12},
+		{"COMPRA 14/06/2026 PARC 02/05", 2, 5},
+		{"COMPRA 14/06/2026", 0, 0},
+		{"ASSINATURA 12/2026", 0, 0},
+		{"11/10", 0, 0},
+		{"sem parcela", 0, 0},
+	}
+
+	for _, c := range cases {
+		idx, total := p.parseInstallment(c.input)
+		if idx != c.wantIndex || total != c.wantTotal {
+			t.Errorf("parseInstallment(%q) = %d/%d, want %d/%d", c.input, idx, total, c.wantIndex, c.wantTotal)
+		}
+	}
+}
+
 func makeTransaction(dono, banco, conta string, valor float64) models.Transaction {
-	return models.Transaction{Dono: dono, Banco: banco, Conta: conta, Valor: valor}
+	return models.Transaction{Dono: dono, Banco: banco, Conta: conta, Valor: reais(valor)}
 }
 
 func TestIsMatch(t *testing.T) {
@@ -229,14 +330,109 @@ func TestIsMatch(t *testing.T) {
 		},
 	}
 
+	tol := (&Logic{}).toleranceFor(base)
 	for _, c := range cases {
-		got := isMatch(c.dif, c.es)
+		got := isMatch(c.dif, c.es, tol)
 		if got != c.expected {
 			t.Errorf("[%s] isMatch() = %v, want %v", c.desc, got, c.expected)
 		}
 	}
 }
 
+// --- Tolerância ---
+
+func TestToleranceFor_DefaultWhenUnconfigured(t *testing.T) {
+	tol := (&Logic{}).toleranceFor(makeTransaction("Alice", "BancoBR", "Corrente", 100.0))
+	if tol.Rule != "default" || tol.Limit != reais(config.DefaultMatchTolerance) {
+		t.Errorf("unexpected tolerance: %+v", tol)
+	}
+}
+
+func TestToleranceFor_PercentWinsOverAbsoluteForLargeValues(t *testing.T) {
+	l := &Logic{cfg: config.Config{MatchTolerance: config.ToleranceRule{Absolute: 5, Percent: 2}}}
+
+	small := l.toleranceFor(makeTransaction("Alice", "BancoBR", "Corrente", 20.0))
+	if small.Limit != reais(5) {
+		t.Errorf("expected absolute limit 5 for small value, got %v", small.Limit)
+	}
+	large := l.toleranceFor(makeTransaction("Alice", "BancoBR", "Corrente", -1000.0))
+	if large.Limit != reais(20) {
+		t.Errorf("expected 2%% limit 20 for large value, got %v", large.Limit)
+	}
+}
+
+func TestToleranceFor_MostSpecificOverrideWins(t *testing.T) {
+	l := &Logic{cfg: config.Config{
+		MatchTolerance: config.ToleranceRule{Absolute: 5},
+		ToleranceRules: []config.ToleranceRule{
+			{Banco: "Nubank", Absolute: 1},
+			{Dono: "Alice", Banco: "Nubank", Absolute: 0.5},
+			{Dono: "Bob", Absolute: 10},
+		},
+	}}
+
+	cases := []struct {
+		desc     string
+		t        models.Transaction
+		wantRule string
+		wantLim  float64
+	}{
+		{"dono e banco casam", makeTransaction("Alice", "Nubank", "Cartao", 100), "dono=Alice, banco=Nubank", 0.5},
+		{"so banco casa", makeTransaction("Carol", "Nubank", "Cartao", 100), "banco=Nubank", 1},
+		{"nenhuma casa", makeTransaction("Carol", "Itau", "Cartao", 100), "default", 5},
+	}
+	for _, c := range cases {
+		got := l.toleranceFor(c.t)
+		if got.Rule != c.wantRule || got.Limit != reais(c.wantLim) {
+			t.Errorf("[%s] toleranceFor() = %+v, want rule %q limit %v", c.desc, got, c.wantRule, c.wantLim)
+		}
+	}
+}
+
+// --- Pontuação ---
+
+func TestScoreCandidate_PrefersSameInstallmentAndCloserValue(t *testing.T) {
+	l := &Logic{}
+	dif := models.Transaction{Dono: "Alice", Valor: reais(100), Descricao: "LOJA X PARC 03/10", Data: p.parseDateCell("10/03/2026"), Parcela: 3, TotalParcelas: 10}
+	tol := l.toleranceFor(dif)
+
+	same := l.scoreCandidate(dif, models.Transaction{Valor: reais(100), Descricao: "Loja X 3/10", Data: p.parseDateCell("08/03/2026"), Parcela: 3, TotalParcelas: 10}, tol)
+	other := l.scoreCandidate(dif, models.Transaction{Valor: reais(100), Descricao: "Loja X 4/10", Data: p.parseDateCell("08/04/2026"), Parcela: 4, TotalParcelas: 10}, tol)
+	far := l.scoreCandidate(dif, models.Transaction{Valor: reais(104), Descricao: "Loja X 3/10", Data: p.parseDateCell("08/03/2026"), Parcela: 3, TotalParcelas: 10}, tol)
+
+	if same.ScoreParts.Parcela != 1 || other.ScoreParts.Parcela != 0 {
+		t.Errorf("unexpected installment parts: same=%+v other=%+v", same.ScoreParts, other.ScoreParts)
+	}
+	if !(same.Score > other.Score) {
+		t.Errorf("expected same installment to score higher: %v <= %v", same.Score, other.Score)
+	}
+	if !(same.Score > far.Score) {
+		t.Errorf("expected closer value to score higher: %v <= %v", same.Score, far.Score)
+	}
+	if same.Score > 1 || far.Score < 0 {
+		t.Errorf("score out of [0,1]: %v, %v", same.Score, far.Score)
+	}
+}
+
+func TestScoreCandidate_UnknownComponentsAreNeutral(t *testing.T) {
+	l := &Logic{}
+	dif := models.Transaction{Valor: reais(100)}
+	got := l.scoreCandidate(dif, models.Transaction{Valor: reais(100)}, l.toleranceFor(dif))
+	want := models.ScoreBreakdown{Valor: 1, Data: unknownScore, Descricao: unknownScore, Parcela: unknownScore}
+	if got.ScoreParts != want {
+		t.Errorf("ScoreParts = %+v, want %+v", got.ScoreParts, want)
+	}
+}
+
+func TestDescriptionScore_IgnoresCaseAccentsAndNumbers(t *testing.T) {
+	if got := descriptionScore("Padaria São João 01/03", "PADARIA SAO JOAO"); got != 1 {
+		t.Errorf("descriptionScore() = %v, want 1", got)
+	}
+	if got := descriptionScore("Netflix", "Spotify"); got != 0 {
+		t.Errorf("descriptionScore() = %v, want 0", got)
+	}
+}
+
 // --- service-level tests using memRepo (sem rede) ---
 
 func makeRow(dono, banco, conta, valor, idParcela, recorrente string) []interface{} {
@@ -262,7 +458,7 @@ func newTestLogic(t *testing.T, sheetData map[string][][]interface{}) *Logic {
 }
 
 func TestAccept_WritesIdParcelaToES(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-42", "sim")
 	esRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")
 
@@ -273,7 +469,7 @@ func TestAccept_WritesIdParcelaToES(t *testing.T) {
 	logic := newTestLogic(t, repo.sheets)
 	logic.repo = repo
 
-	if err := logic.Accept(1, []int{1}); err != nil {
+	if err := logic.Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1}}); err != nil {
 		t.Fatalf("Accept() error: %v", err)
 	}
 
@@ -287,7 +483,7 @@ func TestAccept_WritesIdParcelaToES(t *testing.T) {
 }
 
 func TestReject_AppendsToREJ(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Bob", "BankX", "Corrente", "50.00", "parcela-99", "sim")
 
 	repo := newMemRepo(map[string][][]interface{}{
@@ -297,7 +493,7 @@ func TestReject_AppendsToREJ(t *testing.T) {
 	logic := newTestLogic(t, repo.sheets)
 	logic.repo = repo
 
-	if err := logic.Reject(1); err != nil {
+	if err := logic.Reject(context.Background(), 1, ""); err != nil {
 		t.Fatalf("Reject() error: %v", err)
 	}
 
@@ -308,8 +504,55 @@ func TestReject_AppendsToREJ(t *testing.T) {
 	}
 }
 
+func TestReject_StaleIdParcela(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Bob", "BankX", "Corrente", "50.00", "parcela-98", "sim")},
+		"REJ": {header},
+	})
+	logic := newTestLogicWithRepo(t, repo)
+
+	if err := logic.Reject(context.Background(), 1, "parcela-99"); !errors.Is(err, ErrStaleRow) {
+		t.Errorf("expected ErrStaleRow when the row moved, got %v", err)
+	}
+	if err := logic.Reject(context.Background(), 2, "parcela-99"); !errors.Is(err, ErrStaleRow) {
+		t.Errorf("expected ErrStaleRow when the row is gone, got %v", err)
+	}
+	if len(repo.appended["REJ"]) != 0 {
+		t.Errorf("expected no append to REJ, got %d", len(repo.appended["REJ"]))
+	}
+}
+
+func TestAccept_RefusesChangedESRow(t *testing.T) {
+	header := testHeader
+	esRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {header, esRow},
+	})
+	seen := p.ParseTransaction(models.DefaultColumns, 1, esRow, "ES")
+	logic := newTestLogicWithRepo(t, repo)
+
+	req := models.AcceptRequest{
+		IdParcela:      "p-1",
+		EsRowIndices:   []int{1},
+		ExpectedEsRows: []models.ExpectedEsRow{{EsRowIndex: 1, Descricao: seen.Descricao, Valor: reais(99.00)}},
+	}
+	if err := logic.Accept(context.Background(), 1, req); !errors.Is(err, ErrStaleRow) {
+		t.Fatalf("expected ErrStaleRow, got %v", err)
+	}
+	if len(repo.written) != 0 {
+		t.Fatalf("expected no WriteCell, got %d", len(repo.written))
+	}
+
+	req.ExpectedEsRows[0].Valor = seen.Valor
+	if err := logic.Accept(context.Background(), 1, req); err != nil {
+		t.Errorf("Accept() with matching expectations error: %v", err)
+	}
+}
+
 func TestListNonRecurringDIF_FiltersCorrectly(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	recurring := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	nonRecurring := makeRow("Bob", "BankX", "Poupanca", "200.00", "", "não")
 	empty := []interface{}{}
@@ -317,7 +560,7 @@ func TestListNonRecurringDIF_FiltersCorrectly(t *testing.T) {
 	logic := newTestLogic(t, map[string][][]interface{}{
 		"DIF": {header, recurring, nonRecurring, empty},
 	})
-	items, err := logic.ListNonRecurringDIF()
+	items, err := logic.ListNonRecurringDIF(context.Background())
 	if err != nil {
 		t.Fatalf("ListNonRecurringDIF() error: %v", err)
 	}
@@ -339,7 +582,7 @@ func newTestLogicWithRepo(t *testing.T, repo *memRepo) *Logic {
 // --- GetConciliations ---
 
 func TestGetConciliations_CountsCandidates(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	esRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")
 
@@ -347,7 +590,7 @@ func TestGetConciliations_CountsCandidates(t *testing.T) {
 		"DIF": {header, difRow},
 		"ES":  {header, esRow},
 	})
-	results, err := newTestLogicWithRepo(t, repo).GetConciliations()
+	results, err := newTestLogicWithRepo(t, repo).GetConciliations(context.Background())
 	if err != nil {
 		t.Fatalf("GetConciliations() error: %v", err)
 	}
@@ -360,14 +603,14 @@ func TestGetConciliations_CountsCandidates(t *testing.T) {
 }
 
 func TestGetConciliations_SkipsNonRecurringDIF(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "não")
 
 	repo := newMemRepo(map[string][][]interface{}{
 		"DIF": {header, difRow},
 		"ES":  {header},
 	})
-	results, err := newTestLogicWithRepo(t, repo).GetConciliations()
+	results, err := newTestLogicWithRepo(t, repo).GetConciliations(context.Background())
 	if err != nil {
 		t.Fatalf("GetConciliations() error: %v", err)
 	}
@@ -379,7 +622,7 @@ func TestGetConciliations_SkipsNonRecurringDIF(t *testing.T) {
 // --- GetConciliationDetails ---
 
 func TestGetConciliationDetails_ReturnsCandidates(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	esRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")
 
@@ -387,29 +630,142 @@ func TestGetConciliationDetails_ReturnsCandidates(t *testing.T) {
 		"DIF": {header, difRow},
 		"ES":  {header, esRow},
 	})
-	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(1)
+	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("GetConciliationDetails() error: %v", err)
 	}
 	if len(result.Candidates) != 1 {
 		t.Errorf("expected 1 candidate, got %d", len(result.Candidates))
 	}
+	if result.Tolerance.Rule != "default" {
+		t.Errorf("expected default tolerance rule, got %+v", result.Tolerance)
+	}
+}
+
+func TestGetConciliationDetails_SortsCandidatesByScore(t *testing.T) {
+	header := testHeader
+	difRow := makeRow("Alice", "BancoBR", "Cartao", "100.00", "p-1", "sim")
+	difRow[models.ColumnDescricao] = "LOJA X"
+	worse := makeRow("Alice", "BancoBR", "Cartao", "103.00", "", "sim")
+	worse[models.ColumnDescricao] = "Outra coisa"
+	better := makeRow("Alice", "BancoBR", "Cartao", "100.00", "", "sim")
+	better[models.ColumnDescricao] = "Loja X"
+
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, difRow},
+		"ES":  {header, worse, better},
+	})
+	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	if len(result.Candidates) != 2 {
+		t.Fatalf("expected 2 candidates, got %d", len(result.Candidates))
+	}
+	if result.Candidates[0].RowIndex != 2 {
+		t.Errorf("expected ES row 2 ranked first, got %d", result.Candidates[0].RowIndex)
+	}
+	if result.Candidates[0].Score <= result.Candidates[1].Score {
+		t.Errorf("expected descending scores, got %v, %v", result.Candidates[0].Score, result.Candidates[1].Score)
+	}
+
+	summary, err := newTestLogicWithRepo(t, repo).GetConciliations(context.Background())
+	if err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	if summary[0].TopScore != result.Candidates[0].Score {
+		t.Errorf("TopScore = %v, want %v", summary[0].TopScore, result.Candidates[0].Score)
+	}
+}
+
+// A parcela 3 não pode ocupar a linha da ES reservada para a parcela 4, mesmo com
+// Valor idêntico; sem marcação de parcela na ES, a linha continua Candidata.
+func TestGetConciliationDetails_RequiresSameInstallment(t *testing.T) {
+	header := testHeader
+	difRow := makeRow("Alice", "BancoBR", "Cartao", "100.00", "p-1", "sim")
+	difRow[models.ColumnDescricao] = "LOJA X PARC 03/10"
+	parcela4 := makeRow("Alice", "BancoBR", "Cartao", "100.00", "", "sim")
+	parcela4[models.ColumnDescricao] = "Loja X 4/10"
+	parcela3 := makeRow("Alice", "BancoBR", "Cartao", "100.00", "", "sim")
+	parcela3[models.ColumnDescricao] = "Loja X 3/10"
+	semParcela := makeRow("Alice", "BancoBR", "Cartao", "100.00", "", "sim")
+	semParcela[models.ColumnDescricao] = "Loja X"
+
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, difRow},
+		"ES":  {header, parcela4, parcela3, semParcela},
+	})
+	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	if result.Reference.Parcela != 3 || result.Reference.TotalParcelas != 10 {
+		t.Errorf("expected reference parcela 3/10, got %d/%d", result.Reference.Parcela, result.Reference.TotalParcelas)
+	}
+	if len(result.Candidates) != 2 {
+		t.Fatalf("expected 2 candidates (3/10 and unmarked), got %+v", result.Candidates)
+	}
+	if result.Candidates[0].RowIndex != 2 {
+		t.Errorf("expected matching installment ranked first, got row %d", result.Candidates[0].RowIndex)
+	}
+}
+
+func TestGetConciliationDetails_MarksCandidatesOutsideDateWindow(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, autoRow("Alice", "100.00", "p-1", "LOJA X", "10/03/2026")},
+		"ES": {header,
+			autoRow("Alice", "100.00", "", "Loja X", "10/12/2026"), // 9 meses depois
+			autoRow("Alice", "101.00", "", "Loja X", "2026-03-12"),
+			autoRow("Alice", "100.00", "", "Loja X", ""), // sem data: não restringe
+		},
+	})
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", MatchDateWindowDays: 15}
+	logic := NewLogic(repo, cfg)
+
+	result, err := logic.GetConciliationDetails(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	if result.DateWindowDays != 15 {
+		t.Errorf("DateWindowDays = %d, want 15", result.DateWindowDays)
+	}
+	if len(result.Candidates) != 3 {
+		t.Fatalf("expected 3 candidates, got %d", len(result.Candidates))
+	}
+	last := result.Candidates[2]
+	if last.RowIndex != 1 || !last.OutOfWindow {
+		t.Errorf("expected ES row 1 last and out of window, got %+v", last)
+	}
+	for _, c := range result.Candidates[:2] {
+		if c.OutOfWindow {
+			t.Errorf("ES row %d should be in window", c.RowIndex)
+		}
+	}
+
+	summary, err := logic.GetConciliations(context.Background())
+	if err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	if summary[0].CandidateCount != 2 {
+		t.Errorf("expected out-of-window candidate excluded from count, got %d", summary[0].CandidateCount)
+	}
 }
 
 func TestGetConciliationDetails_OutOfBounds(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header}})
-	_, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(5)
+	_, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 5)
 	if err == nil {
 		t.Error("expected error for out-of-bounds index")
 	}
 }
 
 func TestGetConciliationDetails_NonRecurring(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "não")
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header, difRow}})
-	_, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(1)
+	_, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
 	if err == nil {
 		t.Error("expected error for non-recurring DIF row")
 	}
@@ -417,20 +773,83 @@ func TestGetConciliationDetails_NonRecurring(t *testing.T) {
 
 // --- Accept error paths ---
 
+func TestAccept_RejectsCandidateOutsideTolerance(t *testing.T) {
+	header := testHeader
+	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
+	esRow := makeRow("Alice", "BancoBR", "Corrente", "130.00", "", "sim")
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, difRow},
+		"ES":  {header, esRow},
+	})
+
+	err := newTestLogicWithRepo(t, repo).Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1}})
+	if !errors.Is(err, ErrCandidateMismatch) {
+		t.Fatalf("expected ErrCandidateMismatch, got %v", err)
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %d", len(repo.written))
+	}
+}
+
+func TestAccept_ReportsEachInvalidRow(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES": {header,
+			makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-0", "sim"),
+			makeRow("Bob", "BancoBR", "Corrente", "100.00", "", "sim"),
+			makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim"),
+		},
+	})
+
+	err := newTestLogicWithRepo(t, repo).Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1, 2, 3, 3, 9}})
+
+	var invalid *AcceptValidationError
+	if !errors.As(err, &invalid) {
+		t.Fatalf("expected *AcceptValidationError, got %v", err)
+	}
+	if !errors.Is(err, ErrEsRowNotPending) {
+		t.Errorf("expected ErrEsRowNotPending when a row is already conciliated, got %v", err)
+	}
+	want := []string{AcceptReasonNotPending, AcceptReasonOtherAccount, AcceptReasonDuplicate, AcceptReasonNotFound}
+	if len(invalid.Rows) != len(want) {
+		t.Fatalf("expected %d failed rows, got %+v", len(want), invalid.Rows)
+	}
+	for i, reason := range want {
+		if invalid.Rows[i].Reason != reason {
+			t.Errorf("row %d: expected reason %q, got %+v", i, reason, invalid.Rows[i])
+		}
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %d", len(repo.written))
+	}
+}
+
+func TestAccept_EmptySelection(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {header},
+	})
+	if err := newTestLogicWithRepo(t, repo).Accept(context.Background(), 1, models.AcceptRequest{}); !errors.Is(err, ErrEmptySelection) {
+		t.Errorf("expected ErrEmptySelection, got %v", err)
+	}
+}
+
 func TestAccept_OutOfBounds(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header}})
-	err := newTestLogicWithRepo(t, repo).Accept(5, []int{1})
+	err := newTestLogicWithRepo(t, repo).Accept(context.Background(), 5, models.AcceptRequest{EsRowIndices: []int{1}})
 	if err == nil {
 		t.Error("expected error for out-of-bounds index")
 	}
 }
 
 func TestAccept_EmptyIdParcela(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header, difRow}})
-	err := newTestLogicWithRepo(t, repo).Accept(1, []int{1})
+	err := newTestLogicWithRepo(t, repo).Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1}})
 	if err == nil {
 		t.Error("expected error for empty IdParcela")
 	}
@@ -439,14 +858,14 @@ func TestAccept_EmptyIdParcela(t *testing.T) {
 // --- Move tests ---
 
 func TestMoveNonRecurringDifToES_MovesRow(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Bob", "BankX", "Poupanca", "200.00", "", "não")
 
 	repo := newMemRepo(map[string][][]interface{}{
 		"DIF": {header, difRow},
 		"ES":  {header},
 	})
-	if err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToES(1); err != nil {
+	if err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToES(context.Background(), 1, ""); err != nil {
 		t.Fatalf("MoveNonRecurringDifToES() error: %v", err)
 	}
 	if len(repo.appended["ES"]) != 1 {
@@ -455,24 +874,24 @@ func TestMoveNonRecurringDifToES_MovesRow(t *testing.T) {
 }
 
 func TestMoveNonRecurringDifToES_RejectsRecurring(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header, difRow}})
-	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToES(1)
+	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToES(context.Background(), 1, "")
 	if err == nil {
 		t.Error("expected error for recurring DIF row")
 	}
 }
 
 func TestMoveNonRecurringDifToREJ_MovesRow(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Bob", "BankX", "Poupanca", "200.00", "", "não")
 
 	repo := newMemRepo(map[string][][]interface{}{
 		"DIF": {header, difRow},
 		"REJ": {header},
 	})
-	if err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(1); err != nil {
+	if err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(context.Background(), 1, ""); err != nil {
 		t.Fatalf("MoveNonRecurringDifToREJ() error: %v", err)
 	}
 	if len(repo.appended["REJ"]) != 1 {
@@ -481,7 +900,7 @@ func TestMoveNonRecurringDifToREJ_MovesRow(t *testing.T) {
 }
 
 func TestMoveAllNonRecurringDifToES_MovesAll(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	row1 := makeRow("Bob", "BankX", "Poupanca", "200.00", "", "não")
 	row2 := makeRow("Carol", "BankY", "Corrente", "300.00", "", "não")
 	recurring := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
@@ -490,7 +909,7 @@ func TestMoveAllNonRecurringDifToES_MovesAll(t *testing.T) {
 		"DIF": {header, row1, row2, recurring},
 		"ES":  {header},
 	})
-	result, err := newTestLogicWithRepo(t, repo).MoveAllNonRecurringDifToES()
+	result, err := newTestLogicWithRepo(t, repo).MoveAllNonRecurringDifToES(context.Background())
 	if err != nil {
 		t.Fatalf("MoveAllNonRecurringDifToES() error: %v", err)
 	}
@@ -500,6 +919,9 @@ func TestMoveAllNonRecurringDifToES_MovesAll(t *testing.T) {
 	if len(repo.appended["ES"]) != 2 {
 		t.Errorf("expected 2 rows appended to ES, got %d", len(repo.appended["ES"]))
 	}
+	if repo.calls != 1 {
+		t.Errorf("expected a single batched append, got %d write calls", repo.calls)
+	}
 }
 
 // --- UpdateDifCategory / UpdateDifDate ---
@@ -507,13 +929,13 @@ func TestMoveAllNonRecurringDifToES_MovesAll(t *testing.T) {
 // A HOM tem a linha-alvo DESLOCADA: se o serviço usasse o índice da DIF, escreveria
 // na linha errada (o bug do #21). Como endereça por IdParcela, acerta a linha certa.
 func TestUpdateDifCategory_WritesCellByIdParcela(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	other := makeRow("Zed", "BancoX", "Corrente", "50.00", "outra-parcela", "não")
 	target := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")
 
 	// alvo na linha 2 da HOM — um índice de DIF apontaria para a linha 1.
 	repo := newMemRepo(map[string][][]interface{}{"HOM": {header, other, target}})
-	if err := newTestLogicWithRepo(t, repo).UpdateDifCategory("parcela-7", "Alimentação"); err != nil {
+	if err := newTestLogicWithRepo(t, repo).UpdateDifCategory(context.Background(), "parcela-7", "Alimentação"); err != nil {
 		t.Fatalf("UpdateDifCategory() error: %v", err)
 	}
 	if len(repo.written) != 1 {
@@ -526,29 +948,48 @@ func TestUpdateDifCategory_WritesCellByIdParcela(t *testing.T) {
 }
 
 func TestUpdateDifDate_WritesCellByIdParcela(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	other := makeRow("Zed", "BancoX", "Corrente", "50.00", "outra-parcela", "não")
 	target := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")
 
 	repo := newMemRepo(map[string][][]interface{}{"HOM": {header, other, target}})
-	if err := newTestLogicWithRepo(t, repo).UpdateDifDate("parcela-7", "2026-06-14"); err != nil {
+	if err := newTestLogicWithRepo(t, repo).UpdateDifDate(context.Background(), "parcela-7", "2026-06-14"); err != nil {
 		t.Fatalf("UpdateDifDate() error: %v", err)
 	}
 	if len(repo.written) != 1 {
 		t.Fatalf("expected 1 WriteCell call, got %d", len(repo.written))
 	}
 	w := repo.written[0]
-	if w.sheet != "HOM" || w.row != 2 || w.col != models.ColumnData || w.value != "2026-06-14" {
+	d, ok := w.value.(models.Date)
+	if w.sheet != "HOM" || w.row != 2 || w.col != models.ColumnData || !ok || d.String() != "14/06/2026" {
 		t.Errorf("unexpected WriteCell: %+v", w)
 	}
+	if d.Serial() != 46187 {
+		t.Errorf("Serial() = %v, want 46187", d.Serial())
+	}
+}
+
+func TestUpdateDifDate_RejectsInvalidDate(t *testing.T) {
+	repo := newMemRepo(map[string][][]interface{}{
+		"HOM": {testHeader, makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")},
+	})
+	for _, data := range []string{"", "ontem", "31/02/2026", "2026-13-01", "2024", "15", "46000", "01/01/1800"} {
+		err := newTestLogicWithRepo(t, repo).UpdateDifDate(context.Background(), "parcela-7", data)
+		if !errors.Is(err, ErrInvalidDate) {
+			t.Errorf("UpdateDifDate(%q) error = %v, want ErrInvalidDate", data, err)
+		}
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no write, got %+v", repo.written)
+	}
 }
 
 func TestUpdateDifCategory_NotInHOM(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	homRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")
 	repo := newMemRepo(map[string][][]interface{}{"HOM": {header, homRow}})
 
-	err := newTestLogicWithRepo(t, repo).UpdateDifCategory("inexistente", "Alimentação")
+	err := newTestLogicWithRepo(t, repo).UpdateDifCategory(context.Background(), "inexistente", "Alimentação")
 	if !errors.Is(err, ErrTransactionNotInHOM) {
 		t.Fatalf("expected ErrTransactionNotInHOM, got %v", err)
 	}
@@ -557,12 +998,28 @@ func TestUpdateDifCategory_NotInHOM(t *testing.T) {
 	}
 }
 
+func TestUpdateDifDate_WritesFarFutureSerial(t *testing.T) {
+	repo := newMemRepo(map[string][][]interface{}{
+		"HOM": {testHeader, makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")},
+	})
+	if err := newTestLogicWithRepo(t, repo).UpdateDifDate(context.Background(), "parcela-7", "31/12/9999"); err != nil {
+		t.Fatalf("UpdateDifDate() error: %v", err)
+	}
+	if len(repo.written) != 1 {
+		t.Fatalf("expected 1 write, got %+v", repo.written)
+	}
+	d, ok := repo.written[0].value.(models.Date)
+	if !ok || d.Serial() != maxDateSerial {
+		t.Errorf("expected serial %d, got %+v", maxDateSerial, repo.written[0].value)
+	}
+}
+
 func TestUpdateDifDate_NotInHOM(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	homRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")
 	repo := newMemRepo(map[string][][]interface{}{"HOM": {header, homRow}})
 
-	err := newTestLogicWithRepo(t, repo).UpdateDifDate("inexistente", "2026-06-14")
+	err := newTestLogicWithRepo(t, repo).UpdateDifDate(context.Background(), "inexistente", "2026-06-14")
 	if !errors.Is(err, ErrTransactionNotInHOM) {
 		t.Fatalf("expected ErrTransactionNotInHOM, got %v", err)
 	}
@@ -573,7 +1030,7 @@ func TestUpdateDifDate_NotInHOM(t *testing.T) {
 
 func TestUpdateDifCategory_EmptyIdParcela(t *testing.T) {
 	repo := newMemRepo(map[string][][]interface{}{})
-	err := newTestLogicWithRepo(t, repo).UpdateDifCategory("  ", "Alimentação")
+	err := newTestLogicWithRepo(t, repo).UpdateDifCategory(context.Background(), "  ", "Alimentação")
 	if !errors.Is(err, ErrEmptyIdParcela) {
 		t.Fatalf("expected ErrEmptyIdParcela, got %v", err)
 	}
@@ -581,7 +1038,7 @@ func TestUpdateDifCategory_EmptyIdParcela(t *testing.T) {
 
 func TestUpdateDifDate_EmptyIdParcela(t *testing.T) {
 	repo := newMemRepo(map[string][][]interface{}{})
-	err := newTestLogicWithRepo(t, repo).UpdateDifDate("", "2026-06-14")
+	err := newTestLogicWithRepo(t, repo).UpdateDifDate(context.Background(), "", "2026-06-14")
 	if !errors.Is(err, ErrEmptyIdParcela) {
 		t.Fatalf("expected ErrEmptyIdParcela, got %v", err)
 	}
@@ -590,14 +1047,14 @@ func TestUpdateDifDate_EmptyIdParcela(t *testing.T) {
 // --- Gaps restantes ---
 
 func TestGetConciliations_SkipsEmptyDIFRow(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	emptyRow := []interface{}{}
 
 	repo := newMemRepo(map[string][][]interface{}{
 		"DIF": {header, emptyRow},
 		"ES":  {header},
 	})
-	results, err := newTestLogicWithRepo(t, repo).GetConciliations()
+	results, err := newTestLogicWithRepo(t, repo).GetConciliations(context.Background())
 	if err != nil {
 		t.Fatalf("GetConciliations() error: %v", err)
 	}
@@ -607,7 +1064,7 @@ func TestGetConciliations_SkipsEmptyDIFRow(t *testing.T) {
 }
 
 func TestGetConciliationDetails_NoMatchingCandidates(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	esRow := makeRow("Bob", "OutroBanco", "Poupanca", "999.00", "", "sim")
 
@@ -615,7 +1072,7 @@ func TestGetConciliationDetails_NoMatchingCandidates(t *testing.T) {
 		"DIF": {header, difRow},
 		"ES":  {header, esRow},
 	})
-	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(1)
+	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
 	if err != nil {
 		t.Fatalf("GetConciliationDetails() error: %v", err)
 	}
@@ -625,35 +1082,62 @@ func TestGetConciliationDetails_NoMatchingCandidates(t *testing.T) {
 }
 
 func TestReject_OutOfBounds(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header}})
-	err := newTestLogicWithRepo(t, repo).Reject(5)
+	err := newTestLogicWithRepo(t, repo).Reject(context.Background(), 5, "")
 	if err == nil {
 		t.Error("expected error for out-of-bounds index")
 	}
 }
 
 func TestMoveNonRecurringDifToREJ_RejectsRecurring(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header, difRow}})
-	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(1)
+	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(context.Background(), 1, "")
 	if err == nil {
 		t.Error("expected error for recurring DIF row")
 	}
 }
 
 func TestMoveNonRecurringDifToREJ_OutOfBounds(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	repo := newMemRepo(map[string][][]interface{}{"DIF": {header}})
-	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(5)
+	err := newTestLogicWithRepo(t, repo).MoveNonRecurringDifToREJ(context.Background(), 5, "")
 	if err == nil {
 		t.Error("expected error for out-of-bounds index")
 	}
 }
 
+func TestDIFActions_RejectHeaderAndNegativeIndex(t *testing.T) {
+	difRow := makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "não")
+	ctx := context.Background()
+	for _, idx := range []int{-1, 0} {
+		repo := newMemRepo(map[string][][]interface{}{"DIF": {testHeader, difRow}})
+		logic := newTestLogicWithRepo(t, repo)
+		if _, err := logic.GetConciliationDetails(ctx, idx); err == nil {
+			t.Errorf("GetConciliationDetails(%d): expected error", idx)
+		}
+		if err := logic.Accept(ctx, idx, models.AcceptRequest{EsRowIndices: []int{1}}); err == nil {
+			t.Errorf("Accept(%d): expected error", idx)
+		}
+		if err := logic.Reject(ctx, idx, ""); err == nil {
+			t.Errorf("Reject(%d): expected error", idx)
+		}
+		if err := logic.MoveNonRecurringDifToES(ctx, idx, ""); err == nil {
+			t.Errorf("MoveNonRecurringDifToES(%d): expected error", idx)
+		}
+		if err := logic.MoveNonRecurringDifToREJ(ctx, idx, ""); err == nil {
+			t.Errorf("MoveNonRecurringDifToREJ(%d): expected error", idx)
+		}
+		if repo.calls != 0 {
+			t.Errorf("index %d: expected no writes, got %d", idx, repo.calls)
+		}
+	}
+}
+
 func TestMoveAllNonRecurringDifToES_SkipsEmptyRows(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	emptyRow := []interface{}{}
 	nonRecurring := makeRow("Bob", "BankX", "Poupanca", "200.00", "", "não")
 
@@ -661,7 +1145,7 @@ func TestMoveAllNonRecurringDifToES_SkipsEmptyRows(t *testing.T) {
 		"DIF": {header, emptyRow, nonRecurring},
 		"ES":  {header},
 	})
-	result, err := newTestLogicWithRepo(t, repo).MoveAllNonRecurringDifToES()
+	result, err := newTestLogicWithRepo(t, repo).MoveAllNonRecurringDifToES(context.Background())
 	if err != nil {
 		t.Fatalf("MoveAllNonRecurringDifToES() error: %v", err)
 	}
@@ -672,15 +1156,690 @@ func TestMoveAllNonRecurringDifToES_SkipsEmptyRows(t *testing.T) {
 
 // Uma linha vazia da HOM não deve casar com um IdParcela não-vazio nem quebrar a busca.
 func TestUpdateDifCategory_SkipsEmptyHOMRow(t *testing.T) {
-	header := []interface{}{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}
+	header := testHeader
 	emptyRow := []interface{}{}
 	target := makeRow("Alice", "BancoBR", "Corrente", "100.00", "parcela-7", "não")
 	repo := newMemRepo(map[string][][]interface{}{"HOM": {header, emptyRow, target}})
 
-	if err := newTestLogicWithRepo(t, repo).UpdateDifCategory("parcela-7", "Alimentação"); err != nil {
+	if err := newTestLogicWithRepo(t, repo).UpdateDifCategory(context.Background(), "parcela-7", "Alimentação"); err != nil {
 		t.Fatalf("UpdateDifCategory() error: %v", err)
 	}
 	if len(repo.written) != 1 || repo.written[0].row != 2 {
 		t.Errorf("expected WriteCell on row 2, got %+v", repo.written)
 	}
 }
+
+// --- AutoConciliate ---
+
+func autoRow(dono, valor, idParcela, descricao, data string) []interface{} {
+	row := makeRow(dono, "BancoBR", "Cartao", valor, idParcela, "sim")
+	row[models.ColumnDescricao] = descricao
+	row[models.ColumnData] = data
+	return row
+}
+
+func TestAutoConciliate_DryRunProposesWithoutWriting(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X PARC 03/10", "10/03/2026"),
+			// duas candidatas: fica para revisão manual
+			autoRow("Bob", "50.00", "p-2", "PADARIA", "10/03/2026"),
+		},
+		"ES": {header,
+			autoRow("Alice", "100.00", "", "Loja X 3/10", "10/03/2026"),
+			autoRow("Bob", "50.00", "", "Padaria", "10/03/2026"),
+			autoRow("Bob", "51.00", "", "Padaria", "11/03/2026"),
+		},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).AutoConciliate(context.Background(), false)
+	if err != nil {
+		t.Fatalf("AutoConciliate() error: %v", err)
+	}
+	if !result.DryRun {
+		t.Error("expected DryRun=true")
+	}
+	if len(result.Items) != 1 || result.Skipped != 1 {
+		t.Fatalf("expected 1 item and 1 skipped, got %+v", result)
+	}
+	item := result.Items[0]
+	if item.DifRowIndex != 1 || item.EsRowIndex != 1 || item.IdParcela != "p-1" || item.Status != AutoStatusProposed {
+		t.Errorf("unexpected item: %+v", item)
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("dry-run must not write, got %+v", repo.written)
+	}
+}
+
+func TestAutoConciliate_CommitAcceptsThroughAcceptPath(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, autoRow("Alice", "100.00", "p-1", "LOJA X PARC 03/10", "10/03/2026")},
+		"ES":  {header, autoRow("Alice", "100.00", "", "Loja X 3/10", "10/03/2026")},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).AutoConciliate(context.Background(), true)
+	if err != nil {
+		t.Fatalf("AutoConciliate() error: %v", err)
+	}
+	if len(result.Items) != 1 || result.Items[0].Status != AutoStatusAccepted {
+		t.Fatalf("expected 1 accepted item, got %+v", result.Items)
+	}
+	if len(repo.written) != 1 || repo.written[0].row != 1 || repo.written[0].value != "p-1" {
+		t.Errorf("unexpected WriteCell: %+v", repo.written)
+	}
+}
+
+func TestAutoConciliate_CommitWritesAllPairsFromOneRead(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X PARC 03/10", "10/03/2026"),
+			autoRow("Bob", "250.00", "p-2", "MERCADO Y PARC 01/02", "12/03/2026"),
+		},
+		"ES": {header,
+			autoRow("Alice", "100.00", "", "Loja X 3/10", "10/03/2026"),
+			autoRow("Bob", "250.00", "", "Mercado Y 1/2", "12/03/2026"),
+		},
+		"AUD": {{"Quando", "Ação", "IdParcela", "Aba", "Linha", "Antes", "Depois"}},
+	})
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM", SheetAUD: "AUD"}
+
+	result, err := NewLogic(repo, cfg).AutoConciliate(context.Background(), true)
+	if err != nil {
+		t.Fatalf("AutoConciliate() error: %v", err)
+	}
+	if len(result.Items) != 2 || result.Items[0].Status != AutoStatusAccepted || result.Items[1].Status != AutoStatusAccepted {
+		t.Fatalf("expected 2 accepted items, got %+v", result.Items)
+	}
+	if repo.fetches != 1 {
+		t.Errorf("expected the sheets to be read once, got %d reads", repo.fetches)
+	}
+	// uma WriteCells na ES e um AppendRows na AUD
+	if repo.calls != 2 || len(repo.written) != 2 || len(repo.appended["AUD"]) != 2 {
+		t.Errorf("expected one batched write and one audit append, got calls=%d written=%+v audit=%v",
+			repo.calls, repo.written, repo.appended["AUD"])
+	}
+}
+
+func TestAutoConciliate_SkipsESRowClaimedByTwoDIFRows(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "101.00", "p-2", "LOJA X", "10/03/2026"),
+		},
+		"ES": {header, autoRow("Alice", "100.00", "", "Loja X", "10/03/2026")},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).AutoConciliate(context.Background(), true)
+	if err != nil {
+		t.Fatalf("AutoConciliate() error: %v", err)
+	}
+	if len(result.Items) != 0 || result.Skipped != 2 {
+		t.Errorf("expected both DIF rows skipped, got %+v", result)
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no WriteCell, got %+v", repo.written)
+	}
+}
+
+// --- Atribuição global ---
+
+func TestMinCostAssignment(t *testing.T) {
+	cases := []struct {
+		desc string
+		cost [][]float64
+		want []int
+	}{
+		{
+			desc: "quadrada: o guloso pegaria (0,0) e forçaria (1,1) caro",
+			cost: [][]float64{{0.1, 0.2}, {0.15, 0.9}},
+			want: []int{1, 0},
+		},
+		{
+			desc: "mais colunas que linhas",
+			cost: [][]float64{{0.5, 0.1, 0.3}},
+			want: []int{1},
+		},
+		{
+			desc: "mais linhas que colunas: uma fica sem par",
+			cost: [][]float64{{0.3}, {0.1}, {0.2}},
+			want: []int{-1, 0, -1},
+		},
+	}
+
+	for _, c := range cases {
+		got := minCostAssignment(c.cost)
+		if len(got) != len(c.want) {
+			t.Errorf("[%s] got %v, want %v", c.desc, got, c.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("[%s] got %v, want %v", c.desc, got, c.want)
+				break
+			}
+		}
+	}
+}
+
+// Três parcelas da DIF casam com a mesma linha pendente da ES: a atribuição global
+// entrega a linha a uma só DIF e sinaliza a disputa.
+func TestGetAssignment_FlagsESRowClaimedByManyDIFRows(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "101.00", "p-1", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "100.00", "p-2", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "102.00", "p-3", "LOJA X", "10/03/2026"),
+		},
+		"ES": {header, autoRow("Alice", "100.00", "", "Loja X", "10/03/2026")},
+	})
+	logic := newTestLogicWithRepo(t, repo)
+
+	result, err := logic.GetAssignment(context.Background())
+	if err != nil {
+		t.Fatalf("GetAssignment() error: %v", err)
+	}
+	if len(result.Pairs) != 1 || result.Pairs[0].DifRowIndex != 2 || result.Pairs[0].EsRowIndex != 1 {
+		t.Fatalf("expected DIF row 2 (closest value) paired with ES row 1, got %+v", result.Pairs)
+	}
+	if !result.Pairs[0].Contested {
+		t.Error("expected pair to be flagged as contested")
+	}
+	if len(result.Conflicts) != 1 || len(result.Conflicts[0].DifRowIndices) != 3 || result.Conflicts[0].AssignedDifRowIndex != 2 {
+		t.Errorf("unexpected conflicts: %+v", result.Conflicts)
+	}
+	if len(result.Unassigned) != 2 {
+		t.Errorf("expected 2 unassigned DIF rows, got %v", result.Unassigned)
+	}
+
+	summary, err := logic.GetConciliations(context.Background())
+	if err != nil {
+		t.Fatalf("GetConciliations() error: %v", err)
+	}
+	for _, s := range summary {
+		if !s.Contested {
+			t.Errorf("expected DIF row %d to be contested", s.DifRowIndex)
+		}
+		wantProposed := 0
+		if s.DifRowIndex == 2 {
+			wantProposed = 1
+		}
+		if s.ProposedEsRowIndex != wantProposed {
+			t.Errorf("DIF row %d: ProposedEsRowIndex=%d, want %d", s.DifRowIndex, s.ProposedEsRowIndex, wantProposed)
+		}
+	}
+
+	details, err := logic.GetConciliationDetails(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	if got := details.Candidates[0].ClaimedBy; len(got) != 2 {
+		t.Errorf("expected candidate claimed by 2 other DIF rows, got %v", got)
+	}
+}
+
+func TestGetAssignment_MarksFreeContestedRowWithMinusOne(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "101.00", "p-2", "LOJA X", "10/03/2026"),
+		},
+		// a linha 1 é Candidata das duas DIFs, mas cada uma tem outra mais próxima
+		"ES": {header,
+			autoRow("Alice", "100.50", "", "Outra coisa", "10/03/2026"),
+			autoRow("Alice", "100.00", "", "Loja X", "10/03/2026"),
+			autoRow("Alice", "101.00", "", "Loja X", "10/03/2026"),
+		},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).GetAssignment(context.Background())
+	if err != nil {
+		t.Fatalf("GetAssignment() error: %v", err)
+	}
+	want := map[int]int{1: -1, 2: 1, 3: 2}
+	if len(result.Conflicts) != len(want) {
+		t.Fatalf("expected %d conflicts, got %+v", len(want), result.Conflicts)
+	}
+	for _, c := range result.Conflicts {
+		if c.AssignedDifRowIndex != want[c.EsRowIndex] {
+			t.Errorf("ES row %d: assigned to %d, want %d", c.EsRowIndex, c.AssignedDifRowIndex, want[c.EsRowIndex])
+		}
+		if !slices.Equal(c.DifRowIndices, []int{1, 2}) {
+			t.Errorf("ES row %d: DIF rows %v, want [1 2]", c.EsRowIndex, c.DifRowIndices)
+		}
+	}
+}
+
+func TestGetAssignment_PrefersGlobalOptimumOverGreedy(t *testing.T) {
+	header := testHeader
+	// DIF 1 casa com ES 1 e ES 2; DIF 2 só com ES 1. O guloso daria ES 1 à DIF 1 e
+	// deixaria a DIF 2 sem par; o ótimo global dá um par para cada.
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "96.00", "p-2", "LOJA X", "10/03/2026"),
+		},
+		"ES": {header,
+			autoRow("Alice", "99.00", "", "Loja X", "10/03/2026"),
+			autoRow("Alice", "104.00", "", "Loja X", "10/03/2026"),
+		},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).GetAssignment(context.Background())
+	if err != nil {
+		t.Fatalf("GetAssignment() error: %v", err)
+	}
+	got := make(map[int]int)
+	for _, pair := range result.Pairs {
+		got[pair.DifRowIndex] = pair.EsRowIndex
+	}
+	if got[1] != 2 || got[2] != 1 {
+		t.Errorf("expected DIF1→ES2 and DIF2→ES1, got %v", got)
+	}
+}
+
+// --- Split/merge ---
+
+func TestSubsetsNear_FindsBoundedCombinations(t *testing.T) {
+	pool := []models.Transaction{
+		{RowIndex: 1, Valor: reais(40)}, {RowIndex: 2, Valor: reais(60)}, {RowIndex: 3, Valor: reais(30)},
+		{RowIndex: 4, Valor: reais(30)}, {RowIndex: 5, Valor: reais(500)},
+	}
+
+	got := subsetsNear(pool, reais(100), reais(1), 2, 3)
+	sums := make(map[models.Money]int)
+	for _, combo := range got {
+		var sum models.Money
+		for _, tx := range combo {
+			sum += tx.Valor
+		}
+		sums[sum]++
+		if len(combo) < 2 || len(combo) > 3 {
+			t.Errorf("combination size out of bounds: %+v", combo)
+		}
+	}
+	// 40+60, 40+30+30 — 60+30+30 passa do alvo e 500 nem entra no pool.
+	if len(got) != 2 || sums[reais(100)] != 2 {
+		t.Errorf("expected 2 combinations summing 100, got %+v", got)
+	}
+
+	if got := subsetsNear(pool, reais(100), reais(1), 2, 2); len(got) != 1 {
+		t.Errorf("expected only 40+60 with maxSize=2, got %+v", got)
+	}
+}
+
+func TestGetConciliationDetails_ReturnsSplitAndMergeGroups(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header,
+			autoRow("Alice", "100.00", "p-1", "LOJA X", "10/03/2026"),
+			autoRow("Alice", "150.00", "p-2", "LOJA Y", "10/03/2026"),
+		},
+		"ES": {header,
+			autoRow("Alice", "60.00", "", "Loja X a", "10/03/2026"),
+			autoRow("Alice", "40.50", "", "Loja X b", "10/03/2026"),
+			autoRow("Alice", "250.00", "", "Lojas X e Y", "10/03/2026"),
+			autoRow("Bob", "40.00", "", "Outro dono", "10/03/2026"),
+		},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).GetConciliationDetails(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("GetConciliationDetails() error: %v", err)
+	}
+	var split, merge *models.CandidateGroup
+	for i := range result.Groups {
+		switch result.Groups[i].Kind {
+		case GroupKindSplit:
+			split = &result.Groups[i]
+		case GroupKindMerge:
+			merge = &result.Groups[i]
+		}
+	}
+	if split == nil || len(split.EsRowIndices) != 2 || split.Total != reais(100.5) || split.Difference != reais(0.5) {
+		t.Errorf("unexpected split group: %+v", split)
+	}
+	if merge == nil || len(merge.DifRowIndices) != 2 || merge.EsRowIndices[0] != 3 || merge.Total != reais(250) {
+		t.Errorf("unexpected merge group: %+v", merge)
+	}
+}
+
+func TestAccept_AcceptsSplitWhoseSumMatches(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES": {header,
+			makeRow("Alice", "BancoBR", "Corrente", "60.00", "", "sim"),
+			makeRow("Alice", "BancoBR", "Corrente", "41.00", "", "sim"),
+			makeRow("Alice", "BancoBR", "Corrente", "70.00", "", "sim"),
+		},
+	})
+	logic := newTestLogicWithRepo(t, repo)
+
+	if err := logic.Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1, 2}}); err != nil {
+		t.Fatalf("Accept() split error: %v", err)
+	}
+	if len(repo.written) != 2 || repo.calls != 1 {
+		t.Fatalf("expected 2 cells in a single batched write, got %d cells in %d calls", len(repo.written), repo.calls)
+	}
+
+	if err := logic.Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1, 3}}); !errors.Is(err, ErrCandidateMismatch) {
+		t.Errorf("expected ErrCandidateMismatch for 60+70, got %v", err)
+	}
+}
+
+// --- Unlink / auditoria ---
+
+func TestAccept_RecordsAuditEntryWithPreviousValue(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "synthetic-9", "sim")},
+	})
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetAUD: "AUD"}
+
+	if err := NewLogic(repo, cfg).Accept(context.Background(), 1, models.AcceptRequest{EsRowIndices: []int{1}}); err != nil {
+		t.Fatalf("Accept() error: %v", err)
+	}
+	if len(repo.appended["AUD"]) != 1 {
+		t.Fatalf("expected 1 audit row, got %d", len(repo.appended["AUD"]))
+	}
+	entry := repo.appended["AUD"][0]
+	if entry[auditColumnAction] != AuditActionAccept || entry[auditColumnIdParcela] != "p-1" ||
+		entry[auditColumnRow] != "1" || entry[auditColumnBefore] != "synthetic-9" || entry[auditColumnAfter] != "p-1" {
+		t.Errorf("unexpected audit entry: %v", entry)
+	}
+}
+
+func TestUnlink_ClearsIdParcelaWithoutAuditTrail(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"ES": {header,
+			makeRow("Alice", "BancoBR", "Corrente", "60.00", "p-1", "sim"),
+			makeRow("Bob", "BancoBR", "Corrente", "10.00", "p-2", "sim"),
+			makeRow("Alice", "BancoBR", "Corrente", "40.00", " p-1 ", "sim"),
+		},
+	})
+
+	result, err := newTestLogicWithRepo(t, repo).Unlink(context.Background(), "p-1")
+	if err != nil {
+		t.Fatalf("Unlink() error: %v", err)
+	}
+	if len(result.Rows) != 2 || len(repo.written) != 2 {
+		t.Fatalf("expected both split rows unlinked, got %+v / %+v", result.Rows, repo.written)
+	}
+	for i, w := range repo.written {
+		if w.sheet != "ES" || w.col != models.ColumnIdParcela || w.value != "" {
+			t.Errorf("unexpected WriteCell #%d: %+v", i, w)
+		}
+	}
+	if repo.written[0].row != 1 || repo.written[1].row != 3 {
+		t.Errorf("expected rows 1 and 3, got %+v", repo.written)
+	}
+}
+
+func TestUnlink_RestoresSyntheticIdFromAuditTrail(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"ES": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"AUD": {AuditHeader,
+			{"2026-03-01T10:00:00Z", AuditActionAccept, "p-1", "ES", "1", "synthetic-9", "p-1"},
+			{"2026-03-02T10:00:00Z", AuditActionAccept, "p-7", "ES", "1", "synthetic-3", "p-7"},
+		},
+	})
+	cfg := config.Config{SheetES: "ES", SheetAUD: "AUD"}
+
+	result, err := NewLogic(repo, cfg).Unlink(context.Background(), "p-1")
+	if err != nil {
+		t.Fatalf("Unlink() error: %v", err)
+	}
+	if len(result.Rows) != 1 || result.Rows[0].RestoredIdParcela != "synthetic-9" {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if len(repo.written) != 1 || repo.written[0].value != "synthetic-9" {
+		t.Errorf("unexpected WriteCell: %+v", repo.written)
+	}
+	if len(repo.appended["AUD"]) != 1 || repo.appended["AUD"][0][auditColumnAction] != AuditActionUnlink {
+		t.Errorf("expected unlink audit entry, got %v", repo.appended["AUD"])
+	}
+}
+
+func TestUnlink_NotInES(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"ES": {header, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-2", "sim")},
+	})
+
+	_, err := newTestLogicWithRepo(t, repo).Unlink(context.Background(), "p-1")
+	if !errors.Is(err, ErrIdParcelaNotInES) {
+		t.Fatalf("expected ErrIdParcelaNotInES, got %v", err)
+	}
+	if _, err := newTestLogicWithRepo(t, repo).Unlink(context.Background(), " "); !errors.Is(err, ErrEmptyIdParcela) {
+		t.Errorf("expected ErrEmptyIdParcela, got %v", err)
+	}
+}
+
+// --- RestoreRejected ---
+
+func TestRestoreRejected_DeletesREJRowsBottomUp(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"REJ": {header,
+			makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "não"),
+			makeRow("Bob", "BancoBR", "Corrente", "50.00", "p-2", "não"),
+			makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "não"),
+		},
+	})
+
+	l := newTestLogicWithRepo(t, repo)
+	l.cfg.SheetAUD = "AUD"
+	if err := l.RestoreRejected(context.Background(), " p-1 "); err != nil {
+		t.Fatalf("RestoreRejected() error: %v", err)
+	}
+	got := repo.deleted["REJ"]
+	if len(got) != 2 || got[0] != 1 || got[1] != 3 {
+		t.Errorf("expected REJ rows 1 and 3 deleted, got %v", got)
+	}
+	// Uma chamada para apagar e outra para a auditoria.
+	if repo.calls != 2 {
+		t.Errorf("expected a single batched delete, got %d write calls", repo.calls)
+	}
+	aud := repo.appended["AUD"]
+	if len(aud) != 1 || aud[0][auditColumnRow] != "1,3" {
+		t.Errorf("expected a single audit entry for rows 1,3, got %v", aud)
+	}
+}
+
+func TestRestoreRejected_NotInREJ(t *testing.T) {
+	header := testHeader
+	repo := newMemRepo(map[string][][]interface{}{
+		"REJ": {header, makeRow("Bob", "BancoBR", "Corrente", "50.00", "p-2", "não")},
+	})
+
+	err := newTestLogicWithRepo(t, repo).RestoreRejected(context.Background(), "p-1")
+	if !errors.Is(err, ErrIdParcelaNotInREJ) {
+		t.Fatalf("expected ErrIdParcelaNotInREJ, got %v", err)
+	}
+	if len(repo.deleted["REJ"]) != 0 {
+		t.Errorf("expected no DeleteRow, got %v", repo.deleted["REJ"])
+	}
+}
+
+// --- Colunas pelo cabeçalho ---
+
+// shifted insere uma coluna "Obs" antes da Valor, como alguém faria na planilha.
+func shifted(row []interface{}) []interface{} {
+	out := append([]interface{}{}, row[:models.ColumnValor]...)
+	out = append(out, "obs")
+	return append(out, row[models.ColumnValor:]...)
+}
+
+func TestColumns_ReportsMissingAndRepeatedHeaders(t *testing.T) {
+	header := append([]interface{}{}, testHeader...)
+	header[models.ColumnIdParcela] = "Parcela"
+	header = append(header, " valor ")
+
+	_, err := p.Columns(config.DefaultColumnHeaders, header)
+	if !errors.Is(err, ErrMissingHeader) {
+		t.Fatalf("expected ErrMissingHeader, got %v", err)
+	}
+	if !strings.Contains(err.Error(), `"Valor" is in 2 columns`) || !strings.Contains(err.Error(), `"IdParcela" is missing`) {
+		t.Errorf("expected both problems in the error, got %v", err)
+	}
+}
+
+func TestColumns_FollowsTheHeader(t *testing.T) {
+	cols, err := p.Columns(config.DefaultColumnHeaders, shifted(testHeader))
+	if err != nil {
+		t.Fatalf("Columns() error: %v", err)
+	}
+	if cols.Data != models.ColumnData || cols.Valor != models.ColumnValor+1 || cols.IdParcela != models.ColumnIdParcela+1 {
+		t.Errorf("unexpected columns: %+v", cols)
+	}
+}
+
+func TestGetConciliations_FailsOnMissingHeader(t *testing.T) {
+	logic := newTestLogic(t, map[string][][]interface{}{
+		"DIF": {testHeader, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {testHeader[:models.ColumnIdParcela], makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim")},
+	})
+	if _, err := logic.GetConciliations(context.Background()); !errors.Is(err, ErrMissingHeader) {
+		t.Errorf("expected ErrMissingHeader, got %v", err)
+	}
+}
+
+func TestAccept_WritesToTheColumnOfTheESHeader(t *testing.T) {
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {testHeader, makeRow("Alice", "BancoBR", "Corrente", "100.00", "p-1", "sim")},
+		"ES":  {shifted(testHeader), shifted(makeRow("Alice", "BancoBR", "Corrente", "100.00", "", "sim"))},
+	})
+	logic := newTestLogicWithRepo(t, repo)
+
+	items, err := logic.GetConciliations(context.Background())
+	if err != nil || len(items) != 1 || items[0].CandidateCount != 1 {
+		t.Fatalf("expected the shifted ES row as candidate, got %+v (%v)", items, err)
+	}
+	if err := logic.Accept(context.Background(), 1, models.AcceptRequest{IdParcela: "p-1", EsRowIndices: []int{1}}); err != nil {
+		t.Fatalf("Accept() error: %v", err)
+	}
+	if len(repo.written) != 1 || repo.written[0].col != models.ColumnIdParcela+1 {
+		t.Errorf("expected IdParcela written to column %d, got %+v", models.ColumnIdParcela+1, repo.written)
+	}
+}
+
+func TestReject_RemapsTheRowToTheREJLayout(t *testing.T) {
+	rejHeader := []interface{}{"Parcela", "Quando", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente"}
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {testHeader, makeRow("Bob", "BankX", "Corrente", "50.00", "parcela-99", "sim")},
+		"REJ": {rejHeader},
+	})
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM",
+		TabColumnHeaders: map[string]config.ColumnHeaders{"REJ": {IdParcela: "Parcela", Data: "Quando"}}}
+
+	if err := NewLogic(repo, cfg).Reject(context.Background(), 1, "parcela-99"); err != nil {
+		t.Fatalf("Reject() error: %v", err)
+	}
+	got := repo.appended["REJ"]
+	if len(got) != 1 || len(got[0]) != len(rejHeader) || got[0][0] != "parcela-99" || got[0][models.ColumnValor] != reais(50) {
+		t.Errorf("expected the row in the REJ layout, got %v", got)
+	}
+}
+
+// --- Modo estrito ---
+
+func TestParseTransactionStrict_ReportsEveryBadCell(t *testing.T) {
+	row := makeRow("", "BancoBR", "Corrente", "cem reais", "p-1", "talvez")
+	row[models.ColumnData] = "31/02/2026"
+
+	_, err := p.ParseTransactionStrict(models.DefaultColumns, 3, row, "ES")
+	var rowErr *RowError
+	if !errors.As(err, &rowErr) || rowErr.Row != 3 || len(rowErr.Cells) != 4 {
+		t.Fatalf("expected a RowError with 4 cells, got %v", err)
+	}
+	for _, want := range []error{ErrUnparseableRow, ErrInvalidAmount, ErrInvalidDate, ErrInvalidRecorrente, ErrMissingDono} {
+		if !errors.Is(err, want) {
+			t.Errorf("expected errors.Is(%v), got %v", want, err)
+		}
+	}
+}
+
+func TestParseTransactionStrict_AcceptsAValidRow(t *testing.T) {
+	row := autoRow("Alice", "R$ 1.234,56", "p-1", "LOJA", "2026-03-10")
+	row[models.ColumnRecorrente] = "Não"
+	tx, err := p.ParseTransactionStrict(models.DefaultColumns, 1, row, "ES")
+	if err != nil || tx.Valor != reais(1234.56) || tx.Recorrente {
+		t.Errorf("expected a clean parse, got %+v (%v)", tx, err)
+	}
+}
+
+func TestParseTransaction_ReadsTypedCells(t *testing.T) {
+	row := []interface{}{float64(1), float64(46187), "LOJA 3/10", -1234.56, "Casa", "Alice", "BancoBR", "Corrente", true, float64(12345678)}
+	tx, err := p.ParseTransactionStrict(models.DefaultColumns, 1, row, "DIF")
+	if err != nil {
+		t.Fatalf("ParseTransactionStrict() error: %v", err)
+	}
+	if tx.Valor != reais(-1234.56) || tx.Data.String() != "14/06/2026" || !tx.Recorrente ||
+		tx.IdParcela != "12345678" || tx.Parcela != 3 {
+		t.Errorf("unexpected transaction: %+v", tx)
+	}
+}
+
+func TestStrictParsing_SkipsAndRefusesBadRows(t *testing.T) {
+	bad := autoRow("Alice", "R$ ???", "p-1", "LOJA", "10/03/2026")
+	repo := newMemRepo(map[string][][]interface{}{
+		"DIF": {testHeader, bad},
+		"ES":  {testHeader, autoRow("Alice", "0", "", "LOJA", "10/03/2026")},
+	})
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM",
+		MatchTolerance: config.ToleranceRule{Absolute: config.DefaultMatchTolerance}}
+
+	// Sem o modo estrito, o Valor ilegível vira R$ 0,00 e casa com a linha de zero da ES.
+	items, _ := NewLogic(repo, cfg).GetConciliations(context.Background())
+	if len(items) != 1 || items[0].CandidateCount != 1 {
+		t.Fatalf("expected the lenient parse to match, got %+v", items)
+	}
+
+	cfg.StrictParsing = true
+	logic := NewLogic(repo, cfg)
+	if items, _ := logic.GetConciliations(context.Background()); len(items) != 0 {
+		t.Errorf("expected the bad row left out, got %+v", items)
+	}
+	err := logic.Accept(context.Background(), 1, models.AcceptRequest{IdParcela: "p-1", EsRowIndices: []int{1}})
+	if !errors.Is(err, ErrUnparseableRow) || !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("expected ErrUnparseableRow, got %v", err)
+	}
+	if len(repo.written) != 0 {
+		t.Errorf("expected no write, got %+v", repo.written)
+	}
+}
+
+func TestRowDiagnostics_ListsRowsWithProblems(t *testing.T) {
+	noDono := autoRow("", "10,00", "p-2", "PADARIA", "10/03/2026")
+	logic := newTestLogic(t, map[string][][]interface{}{
+		"ES":  {testHeader, autoRow("Alice", "100.00", "", "LOJA", "10/03/2026"), {}},
+		"DIF": {testHeader},
+		"HOM": {testHeader, autoRow("Bob", "abc", "p-1", "LOJA", "ontem"), noDono},
+		"REJ": {testHeader},
+	})
+
+	got, err := logic.RowDiagnostics(context.Background())
+	if err != nil {
+		t.Fatalf("RowDiagnostics() error: %v", err)
+	}
+	if len(got) != 2 || got[0].Sheet != "HOM" || got[0].RowIndex != 1 || got[1].IdParcela != "p-2" {
+		t.Fatalf("expected HOM rows 1 and 2, got %+v", got)
+	}
+	first := got[0].Problems
+	if len(first) != 2 || first[0].Reason != ParseReasonInvalidAmount || first[0].Column != "D" ||
+		first[1].Reason != ParseReasonInvalidDate || first[1].Value != "ontem" {
+		t.Errorf("unexpected problems for HOM row 1: %+v", first)
+	}
+	if second := got[1].Problems; len(second) != 1 || second[0].Reason != ParseReasonMissingDono {
+		t.Errorf("unexpected problems for HOM row 2: %+v", second)
+	}
+}
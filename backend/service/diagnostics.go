@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"olivia-conciliation/backend/models"
+)
+
+// Motivos de uma célula recusada pelo modo estrito, como aparecem no diagnóstico.
+const (
+	ParseReasonInvalidAmount     = "invalid_amount"
+	ParseReasonInvalidDate       = "invalid_date"
+	ParseReasonInvalidRecorrente = "invalid_recorrente"
+	ParseReasonMissingDono       = "missing_dono"
+)
+
+var parseReasons = map[error]string{
+	ErrInvalidAmount:     ParseReasonInvalidAmount,
+	ErrInvalidDate:       ParseReasonInvalidDate,
+	ErrInvalidRecorrente: ParseReasonInvalidRecorrente,
+	ErrMissingDono:       ParseReasonMissingDono,
+}
+
+// RowDiagnostics lista as linhas de ES, DIF, HOM e REJ que o modo estrito recusaria, com
+// cada célula problemática, para corrigir a planilha. Vale com ou sem STRICT_PARSING;
+// linhas vazias ficam de fora.
+func (l *Logic) RowDiagnostics(ctx context.Context) ([]models.RowDiagnostic, error) {
+	tabs := []string{l.cfg.SheetES, l.cfg.SheetDIF, l.cfg.SheetHOM, l.cfg.SheetREJ}
+	sheets, err := l.fetchSheets(ctx, tabs...)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.RowDiagnostic, 0)
+	for n, s := range sheets {
+		for i := 1; i < len(s.rows); i++ {
+			if l.parser.IsEmpty(s.rows[i]) {
+				continue
+			}
+			t, err := l.parser.ParseTransactionStrict(s.cols, i, s.rows[i], tabs[n])
+			var rowErr *RowError
+			if !errors.As(err, &rowErr) {
+				continue
+			}
+			d := models.RowDiagnostic{Sheet: tabs[n], RowIndex: i, IdParcela: t.IdParcela}
+			for _, c := range rowErr.Cells {
+				d.Problems = append(d.Problems, models.CellProblem{
+					Column: models.ColumnLetter(c.Col),
+					Field:  c.Field,
+					Value:  c.Value,
+					Reason: parseReasons[c.Err],
+				})
+			}
+			results = append(results, d)
+		}
+	}
+	return results, nil
+}
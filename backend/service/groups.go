@@ -0,0 +1,163 @@
+package service
+
+import (
+	"sort"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+const (
+	GroupKindSplit = "split" // uma parcela da DIF contra várias linhas da ES
+	GroupKindMerge = "merge" // várias parcelas da DIF contra uma linha da ES
+)
+
+// Limites da busca por combinações. O subset-sum é exponencial; com o pool cortado nas
+// linhas de Valor mais próximo e o tamanho do grupo limitado, a busca fica em poucos
+// milhares de somas por detalhe, o que cabe numa requisição.
+const (
+	maxGroupPool   = 20
+	maxGroupsShown = 10
+)
+
+// limitFor recalcula o limite da tolerância para outro valor de referência — no merge
+// a referência é o Valor da linha da ES, não o da DIF.
+func limitFor(tol models.AppliedTolerance, valor models.Money) models.Money {
+	return max(tol.Absolute, valor.Abs().Percent(tol.Percent))
+}
+
+// sameAccount confere a parte de identidade de isMatch, sem olhar Valor nem parcela.
+func sameAccount(a, b models.Transaction) bool {
+	return a.Dono == b.Dono && a.Banco == b.Banco && a.Conta == b.Conta
+}
+
+// groupSize devolve o tamanho máximo de um grupo, com o padrão quando não configurado.
+func (l *Logic) groupSize() int {
+	if l.cfg.MatchMaxGroupSize < 2 {
+		return config.DefaultMatchMaxGroupSize
+	}
+	return l.cfg.MatchMaxGroupSize
+}
+
+// findGroups procura as combinações de split (a DIF contra várias linhas pendentes da
+// ES) e de merge (a DIF e outras parcelas da DIF contra uma linha pendente da ES) cuja
+// soma fica dentro da tolerância. Só considera linhas do mesmo Dono/Banco/Conta, com o
+// mesmo sinal de Valor e dentro da janela de datas.
+//
+// O split é aceito pelo Aceitar comum, com vários esRowIndices. O merge é só informativo
+// por ora: a coluna IdParcela da ES guarda um único IdParcela, então não há como gravar
+// várias parcelas da DIF numa linha sem mudar a fórmula da DIF.
+func (l *Logic) findGroups(dif models.Transaction, recurring, pending []models.Transaction, tol models.AppliedTolerance) []models.CandidateGroup {
+	k := l.groupSize()
+	groups := make([]models.CandidateGroup, 0)
+
+	var esPool []models.Transaction
+	for _, es := range pending {
+		if sameAccount(dif, es) && sameSign(dif.Valor, es.Valor) && l.inDateWindow(dif, es) {
+			esPool = append(esPool, es)
+		}
+	}
+	for _, combo := range subsetsNear(esPool, dif.Valor, tol.Limit, 2, k) {
+		groups = append(groups, newGroup(GroupKindSplit, []models.Transaction{dif}, combo, dif.Valor))
+	}
+
+	var difPool []models.Transaction
+	for _, other := range recurring {
+		if other.RowIndex != dif.RowIndex && sameAccount(dif, other) && sameSign(dif.Valor, other.Valor) {
+			difPool = append(difPool, other)
+		}
+	}
+	for _, es := range esPool {
+		if es.Valor.Abs() <= dif.Valor.Abs() {
+			continue
+		}
+		// A DIF atual entra em todo grupo; busca-se o restante entre as outras parcelas.
+		rest := es.Valor - dif.Valor
+		for _, combo := range subsetsNear(difPool, rest, limitFor(tol, es.Valor), 1, k-1) {
+			difs := append([]models.Transaction{dif}, combo...)
+			groups = append(groups, newGroup(GroupKindMerge, difs, []models.Transaction{es}, es.Valor))
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		return groups[i].Difference.Abs() < groups[j].Difference.Abs()
+	})
+	if len(groups) > maxGroupsShown {
+		groups = groups[:maxGroupsShown]
+	}
+	return groups
+}
+
+// subsetsNear enumera as combinações de minSize a maxSize transações de pool cuja soma
+// de Valor difere de target em menos de limit. O pool é cortado nas maxGroupPool linhas
+// de Valor mais próximo de target e ordenado por |Valor| crescente, o que permite podar
+// um ramo assim que a soma parcial passa do alvo.
+func subsetsNear(pool []models.Transaction, target, limit models.Money, minSize, maxSize int) [][]models.Transaction {
+	if maxSize < minSize || len(pool) < minSize {
+		return nil
+	}
+
+	candidates := make([]models.Transaction, 0, len(pool))
+	for _, t := range pool {
+		if t.Valor.Abs() < target.Abs()+limit {
+			candidates = append(candidates, t)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return (candidates[i].Valor - target).Abs() < (candidates[j].Valor - target).Abs()
+	})
+	if len(candidates) > maxGroupPool {
+		candidates = candidates[:maxGroupPool]
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Valor.Abs() < candidates[j].Valor.Abs()
+	})
+
+	absTarget := target.Abs()
+	var found [][]models.Transaction
+	var current []models.Transaction
+	var walk func(start int, sum models.Money)
+	walk = func(start int, sum models.Money) {
+		if len(current) >= minSize && (sum-absTarget).Abs() < limit {
+			found = append(found, append([]models.Transaction(nil), current...))
+		}
+		if len(current) == maxSize {
+			return
+		}
+		for i := start; i < len(candidates); i++ {
+			next := sum + candidates[i].Valor.Abs()
+			if next-absTarget >= limit {
+				return // ordenado por |Valor|: os seguintes só aumentam a soma
+			}
+			current = append(current, candidates[i])
+			walk(i+1, next)
+			current = current[:len(current)-1]
+		}
+	}
+	walk(0, 0)
+	return found
+}
+
+func newGroup(kind string, difs, ess []models.Transaction, counterpart models.Money) models.CandidateGroup {
+	g := models.CandidateGroup{Kind: kind}
+	grouped := ess
+	if kind == GroupKindMerge {
+		grouped = difs
+	}
+	for _, t := range difs {
+		g.DifRowIndices = append(g.DifRowIndices, t.RowIndex)
+	}
+	for _, t := range ess {
+		g.EsRowIndices = append(g.EsRowIndices, t.RowIndex)
+	}
+	for _, t := range grouped {
+		g.Total += t.Valor
+	}
+	g.Rows = grouped
+	g.Difference = g.Total - counterpart
+	return g
+}
+
+func sameSign(a, b models.Money) bool {
+	return (a < 0) == (b < 0)
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"olivia-conciliation/backend/models"
+)
+
+// Readiness confere se o backend consegue trabalhar: credenciais, abas e tabelas nativas
+// (quando o repositório é a planilha) e se o cabeçalho de cada aba tem as colunas que o
+// Parser procura. Lê direto da fonte, sem o cache.
+func (l *Logic) Readiness(ctx context.Context) models.ReadinessReport {
+	ctx = withFreshReads(ctx)
+	tabs := []string{l.cfg.SheetES, l.cfg.SheetDIF, l.cfg.SheetREJ, l.cfg.SheetHOM}
+	if l.cfg.SheetAUD != "" {
+		tabs = append(tabs, l.cfg.SheetAUD)
+	}
+
+	var checks []models.HealthCheck
+	repo := l.repo
+	if c, ok := repo.(*CachedRepository); ok {
+		repo = c.next
+	}
+	if lc, ok := repo.(LayoutChecker); ok {
+		checks = append(checks, lc.CheckLayout(ctx, tabs...)...)
+	}
+	checks = append(checks, l.headerChecks(ctx, tabs)...)
+
+	report := models.ReadinessReport{Ready: true, Checks: checks}
+	for _, c := range checks {
+		if !c.OK {
+			report.Ready = false
+		}
+	}
+	return report
+}
+
+func (l *Logic) headerChecks(ctx context.Context, tabs []string) []models.HealthCheck {
+	fetched, err := l.repo.FetchSheets(ctx, tabs...)
+	if err != nil {
+		return []models.HealthCheck{{Name: "headers", Detail: err.Error()}}
+	}
+
+	checks := make([]models.HealthCheck, 0, len(tabs))
+	for _, tab := range tabs {
+		var header []interface{}
+		if rows := fetched[tab]; len(rows) > 0 {
+			header = rows[0]
+		}
+
+		var wrong []string
+		if tab == l.cfg.SheetAUD {
+			for col, want := range AuditHeader {
+				if got := cellString(header, col); !strings.EqualFold(got, fmt.Sprintf("%v", want)) {
+					wrong = append(wrong, fmt.Sprintf("column %s is %q, expected %q", models.ColumnLetter(col), got, want))
+				}
+			}
+		} else if _, err := l.readSheet(tab, fetched[tab]); err != nil {
+			wrong = append(wrong, err.Error())
+		}
+
+		check := models.HealthCheck{Name: "header:" + tab, OK: len(wrong) == 0}
+		if !check.OK {
+			check.Detail = strings.Join(wrong, "; ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
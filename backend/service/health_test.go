@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+// layoutRepo simula a planilha com uma tabela nativa que sumiu.
+type layoutRepo struct {
+	*memRepo
+	checked []string
+}
+
+func (r *layoutRepo) CheckLayout(_ context.Context, sheets ...string) []models.HealthCheck {
+	r.checked = sheets
+	return []models.HealthCheck{{Name: "credentials", OK: true}, {Name: "table:ES", Detail: "gone"}}
+}
+
+func TestReadiness_RunsLayoutChecksBehindTheCache(t *testing.T) {
+	header := make([]interface{}, len(models.TransactionHeader))
+	for i, h := range models.TransactionHeader {
+		header[i] = h
+	}
+	inner := &layoutRepo{memRepo: newMemRepo(map[string][][]interface{}{
+		"DIF": {header}, "ES": {header}, "REJ": {header}, "HOM": {header}, "AUD": {AuditHeader},
+	})}
+	cfg := config.Config{SheetDIF: "DIF", SheetES: "ES", SheetREJ: "REJ", SheetHOM: "HOM", SheetAUD: "AUD", CacheTTL: 1}
+	logic := NewLogic(NewCachedRepository(inner, cfg), cfg)
+
+	report := logic.Readiness(context.Background())
+
+	if report.Ready {
+		t.Error("expected not ready with a failing layout check")
+	}
+	if len(inner.checked) != 5 {
+		t.Errorf("expected the layout check on the 5 tabs, got %v", inner.checked)
+	}
+	// 2 do layout + 1 cabeçalho por aba, todos os cabeçalhos corretos.
+	if len(report.Checks) != 7 {
+		t.Fatalf("expected 7 checks, got %+v", report.Checks)
+	}
+	for _, c := range report.Checks[2:] {
+		if !c.OK {
+			t.Errorf("expected %s to pass, got %+v", c.Name, c)
+		}
+	}
+}
@@ -1,40 +1,154 @@
 package service
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
+	"olivia-conciliation/backend/config"
 	"olivia-conciliation/backend/models"
 )
 
+// ErrMissingHeader sinaliza uma aba em cujo cabeçalho falta (ou se repete) a coluna de um
+// campo da transação. Sem ela o Parser leria o campo errado em silêncio, então nenhuma
+// leitura ou escrita na aba segue adiante. Os handlers respondem 500 com a mensagem.
+var ErrMissingHeader = errors.New("required header not found")
+
 // Parser converts raw spreadsheet rows into domain types.
 type Parser struct{}
 
-func (p Parser) parseFloat(v interface{}) float64 {
-	if v == nil {
-		return 0
+func (p Parser) parseMoney(v interface{}) models.Money {
+	m, _ := p.parseAmount(v)
+	return m
+}
+
+// parseAmount interpreta um Valor. Lido com UNFORMATTED_VALUE ele já chega como número;
+// o texto, no formato do Go ou no pt-BR ("R$ 1.234,56"), fica como alternativa para a
+// leitura formatada e para os backends local e SQLite. ok=false quando vazio ou ilegível.
+func (p Parser) parseAmount(v interface{}) (models.Money, bool) {
+	switch v := v.(type) {
+	case nil:
+		return 0, false
+	case models.Money:
+		return v, true
+	case float64:
+		return models.MoneyFromFloat(v), true
+	}
+	return models.ParseMoney(models.CellText(v))
+}
+
+func (p Parser) parseBool(v interface{}) bool {
+	b, _ := p.parseRecorrente(v)
+	return b
+}
+
+// parseRecorrente interpreta a coluna Recorrente. Vazia vale "não"; ok=false só para um
+// valor que não é nem sim nem não.
+func (p Parser) parseRecorrente(v interface{}) (recorrente, ok bool) {
+	switch v := v.(type) {
+	case nil:
+		return false, true
+	case bool:
+		return v, true
+	}
+	switch strings.ToLower(strings.TrimSpace(models.CellText(v))) {
+	case "sim", "yes", "true":
+		return true, true
+	case "", "não", "nao", "no", "false":
+		return false, true
+	}
+	return false, false
+}
+
+// dateLayouts são os formatos de Data aceitos: o pt-BR da planilha e o ISO do frontend.
+var dateLayouts = []string{models.DateLayout, "2/1/2006", "2006-01-02"}
+
+// maxDateSerial limita o número de série aceito como Data (31/12/9999), para um Valor ou
+// um IdParcela numérico não passar por data.
+const maxDateSerial = 2958465
+
+// parseDate interpreta a Data de uma transação: dd/mm/aaaa, ISO ou o número de série do
+// Sheets (dias desde 30/12/1899), vindo como número ou como texto. ok=false quando vazia
+// ou em formato desconhecido.
+func (p Parser) parseDate(v interface{}) (time.Time, bool) {
+	switch v := v.(type) {
+	case nil:
+		return time.Time{}, false
+	case float64:
+		return dateFromSerial(v)
+	}
+	s := strings.TrimSpace(models.CellText(v))
+	if t, ok := parseDateText(s); ok {
+		return t, true
+	}
+	if serial, err := strconv.ParseFloat(s, 64); err == nil {
+		return dateFromSerial(serial)
 	}
-	s := strings.ReplaceAll(fmt.Sprintf("%v", v), "R$", "")
+	return time.Time{}, false
+}
+
+// parseDateText aceita só os dateLayouts, sem número de série.
+func parseDateText(s string) (time.Time, bool) {
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func dateFromSerial(serial float64) (time.Time, bool) {
+	if serial < 1 || serial > maxDateSerial {
+		return time.Time{}, false
+	}
+	return models.DateFromSerial(serial), true
+}
+
+// parseDateInput interpreta uma Data vinda do cliente. Só aceita texto de data: o número de
+// série é coisa da célula, e do cliente "2024" ou "15" virariam datas de 1905 e 1900.
+// Datas fora do que a planilha guarda (antes de 1900, depois de 9999) também são recusadas.
+func (p Parser) parseDateInput(s string) (models.Date, error) {
 	s = strings.TrimSpace(s)
-	if f, err := strconv.ParseFloat(s, 64); err == nil {
-		return f
+	t, ok := parseDateText(s)
+	if serial := (models.Date{Time: t}).Serial(); serial < 1 || serial > maxDateSerial {
+		ok = false
 	}
-	// pt-BR format: 1.000,00 → remove thousands dot, replace decimal comma
-	s2 := strings.ReplaceAll(s, ".", "")
-	s2 = strings.ReplaceAll(s2, ",", ".")
-	if f, err := strconv.ParseFloat(s2, 64); err == nil {
-		return f
+	if !ok {
+		return models.Date{}, fmt.Errorf("%w: %q (use dd/mm/aaaa ou aaaa-mm-dd)", ErrInvalidDate, s)
 	}
-	return 0
+	return models.Date{Raw: s, Time: t}, nil
 }
 
-func (p Parser) parseBool(v interface{}) bool {
-	if v == nil {
-		return false
+// parseDateCell guarda o texto original da célula junto com a data interpretada.
+func (p Parser) parseDateCell(v interface{}) models.Date {
+	d := models.Date{Raw: models.CellText(v)}
+	if t, ok := p.parseDate(v); ok {
+		d.Time = t
+	}
+	return d
+}
+
+// installmentPattern casa "PARC 03/10", "Parcela 3/10" ou só "3/10". O terceiro grupo
+// existe para descartar datas ("14/06/2026"), que o RE2 não permite excluir com lookahead.
+var installmentPattern = regexp.MustCompile(`\b(\d{1,3})\s*/\s*(\d{1,3})\b(/\d+)?`)
+
+// parseInstallment extrai da Descrição o número da parcela e o total ("3/10" → 3, 10).
+// Devolve zeros quando não há marcação de parcela reconhecível.
+func (p Parser) parseInstallment(descricao string) (index, total int) {
+	for _, m := range installmentPattern.FindAllStringSubmatch(descricao, -1) {
+		if m[3] != "" {
+			continue
+		}
+		n, _ := strconv.Atoi(m[1])
+		t, _ := strconv.Atoi(m[2])
+		if n >= 1 && t >= 2 && n <= t {
+			return n, t
+		}
 	}
-	s := strings.ToLower(fmt.Sprintf("%v", v))
-	return s == "sim" || s == "yes" || s == "true"
+	return 0, 0
 }
 
 func (p Parser) IsEmpty(row []interface{}) bool {
@@ -42,7 +156,7 @@ func (p Parser) IsEmpty(row []interface{}) bool {
 		return true
 	}
 	for _, cell := range row {
-		if strings.TrimSpace(fmt.Sprintf("%v", cell)) != "" {
+		if strings.TrimSpace(models.CellText(cell)) != "" {
 			return false
 		}
 	}
@@ -58,34 +172,162 @@ func (p Parser) IsPending(t models.Transaction) bool {
 	return t.Recorrente && idParcela == ""
 }
 
-func (p Parser) ParseTransaction(idx int, row []interface{}, sheetName string) models.Transaction {
+// Erros do modo estrito, um por tipo de problema numa célula.
+var (
+	ErrInvalidAmount     = errors.New("invalid amount")
+	ErrInvalidDate       = errors.New("invalid date")
+	ErrInvalidRecorrente = errors.New("unknown Recorrente value")
+	ErrMissingDono       = errors.New("missing Dono")
+)
+
+// ErrUnparseableRow sinaliza, no modo estrito, uma ação sobre uma linha com células
+// ilegíveis (ver RowError). Mapeado para HTTP 422.
+var ErrUnparseableRow = errors.New("row has unparseable cells")
+
+// CellError é uma célula que o modo estrito recusou. Err é um dos erros acima.
+type CellError struct {
+	Col   int
+	Field string
+	Value string
+	Err   error
+}
+
+func (e *CellError) Error() string {
+	return fmt.Sprintf("column %s (%s) %q: %v", models.ColumnLetter(e.Col), e.Field, e.Value, e.Err)
+}
+
+func (e *CellError) Unwrap() error { return e.Err }
+
+// RowError reúne as células recusadas de uma linha. errors.Is casa com ErrUnparseableRow
+// e com o erro de cada célula.
+type RowError struct {
+	Sheet string
+	Row   int
+	Cells []*CellError
+}
+
+func (e *RowError) Error() string {
+	parts := make([]string, len(e.Cells))
+	for i, c := range e.Cells {
+		parts[i] = c.Error()
+	}
+	return fmt.Sprintf("%s row %d: %s", e.Sheet, e.Row, strings.Join(parts, "; "))
+}
+
+func (e *RowError) Unwrap() []error {
+	errs := []error{ErrUnparseableRow}
+	for _, c := range e.Cells {
+		errs = append(errs, c)
+	}
+	return errs
+}
+
+// Columns localiza, pelo cabeçalho de uma aba, a coluna de cada campo da transação.
+// Todos os campos são obrigatórios; os que faltam ou aparecem em mais de uma coluna
+// vêm juntos num único ErrMissingHeader.
+func (p Parser) Columns(names config.ColumnHeaders, header []interface{}) (models.ColumnMap, error) {
+	var cols models.ColumnMap
+	fields := []struct {
+		name string
+		col  *int
+	}{
+		{names.Data, &cols.Data},
+		{names.Descricao, &cols.Descricao},
+		{names.Valor, &cols.Valor},
+		{names.Categoria, &cols.Categoria},
+		{names.Dono, &cols.Dono},
+		{names.Banco, &cols.Banco},
+		{names.Conta, &cols.Conta},
+		{names.Recorrente, &cols.Recorrente},
+		{names.IdParcela, &cols.IdParcela},
+	}
+
+	var problems []string
+	for _, f := range fields {
+		var found []int
+		for i := range header {
+			if strings.EqualFold(cellString(header, i), strings.TrimSpace(f.name)) {
+				found = append(found, i)
+			}
+		}
+		switch len(found) {
+		case 0:
+			problems = append(problems, fmt.Sprintf("%q is missing", f.name))
+		case 1:
+			*f.col = found[0]
+		default:
+			problems = append(problems, fmt.Sprintf("%q is in %d columns", f.name, len(found)))
+		}
+	}
+	if len(problems) > 0 {
+		return cols, fmt.Errorf("%w: %s", ErrMissingHeader, strings.Join(problems, ", "))
+	}
+	return cols, nil
+}
+
+// ParseTransaction lê a linha row da aba sheetName com as colunas em cols.
+func (p Parser) ParseTransaction(cols models.ColumnMap, idx int, row []interface{}, sheetName string) models.Transaction {
 	t := models.Transaction{RowIndex: idx, Sheet: sheetName}
-	if len(row) > models.ColumnDono {
-		t.Dono = fmt.Sprintf("%v", row[models.ColumnDono])
+	if len(row) > cols.Dono {
+		t.Dono = models.CellText(row[cols.Dono])
 	}
-	if len(row) > models.ColumnBanco {
-		t.Banco = fmt.Sprintf("%v", row[models.ColumnBanco])
+	if len(row) > cols.Banco {
+		t.Banco = models.CellText(row[cols.Banco])
 	}
-	if len(row) > models.ColumnConta {
-		t.Conta = fmt.Sprintf("%v", row[models.ColumnConta])
+	if len(row) > cols.Conta {
+		t.Conta = models.CellText(row[cols.Conta])
 	}
-	if len(row) > models.ColumnDescricao {
-		t.Descricao = fmt.Sprintf("%v", row[models.ColumnDescricao])
+	if len(row) > cols.Descricao {
+		t.Descricao = models.CellText(row[cols.Descricao])
+		t.Parcela, t.TotalParcelas = p.parseInstallment(t.Descricao)
 	}
-	if len(row) > models.ColumnRecorrente {
-		t.Recorrente = p.parseBool(row[models.ColumnRecorrente])
+	if len(row) > cols.Recorrente {
+		t.Recorrente = p.parseBool(row[cols.Recorrente])
 	}
-	if len(row) > models.ColumnData {
-		t.Data = fmt.Sprintf("%v", row[models.ColumnData])
+	if len(row) > cols.Data {
+		t.Data = p.parseDateCell(row[cols.Data])
 	}
-	if len(row) > models.ColumnValor {
-		t.Valor = p.parseFloat(row[models.ColumnValor])
+	if len(row) > cols.Valor {
+		t.Valor = p.parseMoney(row[cols.Valor])
 	}
-	if len(row) > models.ColumnCategoria {
-		t.Categoria = fmt.Sprintf("%v", row[models.ColumnCategoria])
+	if len(row) > cols.Categoria {
+		t.Categoria = models.CellText(row[cols.Categoria])
 	}
-	if len(row) > models.ColumnIdParcela {
-		t.IdParcela = fmt.Sprintf("%v", row[models.ColumnIdParcela])
+	if len(row) > cols.IdParcela {
+		t.IdParcela = models.CellText(row[cols.IdParcela])
 	}
 	return t
 }
+
+// ParseTransactionStrict lê a linha como ParseTransaction, mas recusa o que aquela
+// aceitaria em silêncio: Valor ilegível (que viraria R$ 0,00), Data fora dos formatos
+// conhecidos, Recorrente que não é sim nem não e Dono vazio. Os problemas vêm todos num
+// *RowError; a transação é devolvida mesmo assim, como ParseTransaction a leria.
+func (p Parser) ParseTransactionStrict(cols models.ColumnMap, idx int, row []interface{}, sheetName string) (models.Transaction, error) {
+	t := p.ParseTransaction(cols, idx, row, sheetName)
+
+	var cells []*CellError
+	check := func(col int, field string, ok bool, err error) {
+		if !ok {
+			cells = append(cells, &CellError{Col: col, Field: field, Value: cellString(row, col), Err: err})
+		}
+	}
+	cell := func(col int) interface{} {
+		if col < len(row) {
+			return row[col]
+		}
+		return nil
+	}
+	_, amountOK := p.parseAmount(cell(cols.Valor))
+	check(cols.Valor, "Valor", amountOK, ErrInvalidAmount)
+	_, dateOK := p.parseDate(cell(cols.Data))
+	check(cols.Data, "Data", dateOK, ErrInvalidDate)
+	_, recorrenteOK := p.parseRecorrente(cell(cols.Recorrente))
+	check(cols.Recorrente, "Recorrente", recorrenteOK, ErrInvalidRecorrente)
+	check(cols.Dono, "Dono", cellString(row, cols.Dono) != "", ErrMissingDono)
+
+	if len(cells) > 0 {
+		return t, &RowError{Sheet: sheetName, Row: idx, Cells: cells}
+	}
+	return t, nil
+}
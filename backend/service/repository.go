@@ -1,9 +1,32 @@
 package service
 
+import (
+	"context"
+
+	"olivia-conciliation/backend/models"
+)
+
 // SheetRepository is the persistence boundary between service logic and Google Sheets.
 // sheets.Client satisfies this interface in production; in-memory adapters are used in tests.
 type SheetRepository interface {
-	FetchRows(sheet string) ([][]interface{}, error)
-	WriteCell(sheet string, rowIdx, colIdx int, value string) error
-	AppendRow(sheet string, values []interface{}) error
+	FetchRows(ctx context.Context, sheet string) ([][]interface{}, error)
+	// FetchSheets reads several tabs in one call, keyed by sheet name. Rows come whole, as
+	// FetchRows returns them: columns are found by header, wherever they are.
+	FetchSheets(ctx context.Context, sheets ...string) (map[string][][]interface{}, error)
+	WriteCell(ctx context.Context, sheet string, rowIdx, colIdx int, value interface{}) error
+	AppendRow(ctx context.Context, sheet string, values []interface{}) error
+	// WriteCells and AppendRows apply a whole batch in one call: all of it lands or none does.
+	WriteCells(ctx context.Context, sheet string, cells []models.CellUpdate) error
+	AppendRows(ctx context.Context, sheet string, rows [][]interface{}) error
+	// DeleteRow removes the row at rowIdx (0-based, header included); rows below shift up.
+	DeleteRow(ctx context.Context, sheet string, rowIdx int) error
+	// DeleteRows removes every row in rowIdxs, given as they are before any deletion and in
+	// any order, in one call: all of them go or none does.
+	DeleteRows(ctx context.Context, sheet string, rowIdxs []int) error
+}
+
+// LayoutChecker is implemented by repositories whose layout lives outside the backend and
+// can drift from what it expects (the spreadsheet). Readiness runs it on the given tabs.
+type LayoutChecker interface {
+	CheckLayout(ctx context.Context, sheets ...string) []models.HealthCheck
 }
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"olivia-conciliation/backend/models"
+)
+
+// Pesos de cada componente da pontuação. Valor pesa mais porque já é o critério de
+// Candidata; os demais desempatam entre várias parcelas do mesmo cartão.
+const (
+	weightValor     = 0.4
+	weightData      = 0.2
+	weightDescricao = 0.2
+	weightParcela   = 0.2
+)
+
+// dateScoreHorizonDays é a distância em dias a partir da qual a data não contribui mais.
+// Um mês cobre a defasagem usual entre a data registrada na ES e a da cobrança.
+const dateScoreHorizonDays = 31.0
+
+// unknownScore é atribuído a componentes sem informação dos dois lados (sem data,
+// sem marcação de parcela): não premia nem penaliza a candidata.
+const unknownScore = 0.5
+
+// scoreCandidate pontua uma Candidata contra a transação da DIF, em [0, 1].
+func (l *Logic) scoreCandidate(dif, es models.Transaction, tol models.AppliedTolerance) models.ScoredCandidate {
+	parts := models.ScoreBreakdown{
+		Valor:     valueScore(dif.Valor, es.Valor, tol.Limit),
+		Data:      dateScore(dif.Data, es.Data),
+		Descricao: descriptionScore(dif.Descricao, es.Descricao),
+		Parcela:   installmentScore(dif, es),
+	}
+	score := weightValor*parts.Valor + weightData*parts.Data +
+		weightDescricao*parts.Descricao + weightParcela*parts.Parcela
+
+	return models.ScoredCandidate{
+		Transaction: es,
+		Score:       round3(score),
+		ScoreParts: models.ScoreBreakdown{
+			Valor:     round3(parts.Valor),
+			Data:      round3(parts.Data),
+			Descricao: round3(parts.Descricao),
+			Parcela:   round3(parts.Parcela),
+		},
+	}
+}
+
+// sortByScore ordena as candidatas da melhor para a pior, com as fora da janela de
+// datas por último e a ordem da planilha preservada no empate.
+func sortByScore(candidates []models.ScoredCandidate) {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].OutOfWindow != candidates[j].OutOfWindow {
+			return !candidates[i].OutOfWindow
+		}
+		return candidates[i].Score > candidates[j].Score
+	})
+}
+
+func valueScore(a, b, limit models.Money) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return clamp01(1 - float64((a-b).Abs())/float64(limit))
+}
+
+func dateScore(a, b models.Date) float64 {
+	if !a.Valid() || !b.Valid() {
+		return unknownScore
+	}
+	return clamp01(1 - float64(models.DaysBetween(a, b))/dateScoreHorizonDays)
+}
+
+// descriptionScore é a similaridade de Jaccard entre as palavras das descrições,
+// ignorando caixa, acentos, pontuação e números (a parcela tem componente próprio).
+func descriptionScore(a, b string) float64 {
+	ta, tb := descriptionTokens(a), descriptionTokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return unknownScore
+	}
+	inter := 0
+	for tok := range ta {
+		if _, ok := tb[tok]; ok {
+			inter++
+		}
+	}
+	union := len(ta) + len(tb) - inter
+	return float64(inter) / float64(union)
+}
+
+// installmentScore premia a mesma parcela N/M. Parcelas divergentes já são barradas
+// por isMatch; o 0 aqui cobre quem pontua pares fora do filtro.
+func installmentScore(dif, es models.Transaction) float64 {
+	if dif.Parcela == 0 || es.Parcela == 0 {
+		return unknownScore
+	}
+	if dif.Parcela != es.Parcela || dif.TotalParcelas != es.TotalParcelas {
+		return 0
+	}
+	return 1
+}
+
+var accentFolder = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a",
+	"é", "e", "ê", "e",
+	"í", "i",
+	"ó", "o", "ô", "o", "õ", "o",
+	"ú", "u", "ü", "u",
+	"ç", "c",
+)
+
+func descriptionTokens(s string) map[string]struct{} {
+	s = accentFolder.Replace(strings.ToLower(s))
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	tokens := make(map[string]struct{}, len(fields))
+	for _, f := range fields {
+		if strings.IndexFunc(f, unicode.IsLetter) < 0 {
+			continue
+		}
+		tokens[f] = struct{}{}
+	}
+	return tokens
+}
+
+func clamp01(x float64) float64 {
+	return math.Max(0, math.Min(1, x))
+}
+
+func round3(x float64) float64 {
+	return math.Round(x*1000) / 1000
+}
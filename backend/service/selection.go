@@ -0,0 +1,130 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+
+	"olivia-conciliation/backend/models"
+)
+
+// Motivos de recusa de uma linha da ES no Aceitar.
+const (
+	AcceptReasonNotFound     = "not_found"
+	AcceptReasonDuplicate    = "duplicate"
+	AcceptReasonNotPending   = "not_pending"
+	AcceptReasonOtherAccount = "other_account"
+	AcceptReasonMismatch     = "mismatch"
+	AcceptReasonChanged      = "changed"
+	AcceptReasonUnparseable  = "unparseable" // só com STRICT_PARSING
+)
+
+// AcceptValidationError lista as linhas da ES recusadas num Aceitar e o motivo de cada
+// uma. errors.Is casa com ErrEsRowNotPending se alguma linha já não estiver pendente,
+// com ErrStaleRow se alguma mudou desde a listagem (nos dois casos a tela está
+// desatualizada) e com ErrCandidateMismatch pelos demais motivos.
+type AcceptValidationError struct {
+	Rows []models.AcceptRowError
+}
+
+func (e *AcceptValidationError) Error() string {
+	parts := make([]string, len(e.Rows))
+	for i, r := range e.Rows {
+		parts[i] = fmt.Sprintf("ES row %d: %s", r.EsRowIndex, r.Detail)
+	}
+	return "invalid accept: " + strings.Join(parts, "; ")
+}
+
+func (e *AcceptValidationError) Unwrap() []error {
+	var errs []error
+	seen := make(map[error]bool)
+	for _, r := range e.Rows {
+		var err error
+		switch r.Reason {
+		case AcceptReasonNotPending:
+			err = ErrEsRowNotPending
+		case AcceptReasonChanged:
+			err = ErrStaleRow
+		default:
+			err = ErrCandidateMismatch
+		}
+		if !seen[err] {
+			seen[err] = true
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// validateSelection confere as linhas da ES pedidas num Aceitar: precisam existir, ter o
+// conteúdo que o cliente viu (quando informado), ser Transações Pendentes da mesma conta
+// da DIF e casar com ela — cada uma sozinha ou, juntas, formando um split (soma de Valor
+// dentro da tolerância). Todas as recusas vêm num único *AcceptValidationError, para a
+// tela mostrar o que mudou de uma vez.
+func (l *Logic) validateSelection(dif models.Transaction, esSheet sheetData, esIndices []int, expected []models.ExpectedEsRow) ([]models.Transaction, error) {
+	var failed []models.AcceptRowError
+	fail := func(idx int, reason, detail string) {
+		failed = append(failed, models.AcceptRowError{EsRowIndex: idx, Reason: reason, Detail: detail})
+	}
+
+	want := make(map[int]models.ExpectedEsRow, len(expected))
+	for _, e := range expected {
+		want[e.EsRowIndex] = e
+	}
+
+	selected := make([]models.Transaction, 0, len(esIndices))
+	seen := make(map[int]bool, len(esIndices))
+	for _, idx := range esIndices {
+		switch {
+		case idx < 1 || idx >= len(esSheet.rows):
+			fail(idx, AcceptReasonNotFound, "row does not exist in ES")
+			continue
+		case seen[idx]:
+			fail(idx, AcceptReasonDuplicate, "row selected more than once")
+			continue
+		}
+		seen[idx] = true
+
+		es, err := l.parse(esSheet, idx, "ES")
+		if err != nil {
+			fail(idx, AcceptReasonUnparseable, err.Error())
+			continue
+		}
+		e, hasExpected := want[idx]
+		switch {
+		case hasExpected && !sameContent(es, e):
+			fail(idx, AcceptReasonChanged, fmt.Sprintf("row changed since it was listed (now %q, R$ %s)", es.Descricao, es.Valor))
+		case !l.parser.IsPending(es):
+			fail(idx, AcceptReasonNotPending, fmt.Sprintf("row is not pending (IdParcela %q)", es.IdParcela))
+		case !sameAccount(dif, es):
+			fail(idx, AcceptReasonOtherAccount, "row belongs to another Dono/Banco/Conta")
+		default:
+			selected = append(selected, es)
+		}
+	}
+	if len(failed) > 0 {
+		return nil, &AcceptValidationError{Rows: failed}
+	}
+
+	tol := l.toleranceFor(dif)
+	var sum models.Money
+	for _, es := range selected {
+		if !isMatch(dif, es, tol) {
+			fail(es.RowIndex, AcceptReasonMismatch, fmt.Sprintf("outside tolerance %q (limit %s)", tol.Rule, tol.Limit))
+		}
+		sum += es.Valor
+	}
+	if len(failed) == 0 {
+		return selected, nil
+	}
+	if len(selected) > 1 && (sum-dif.Valor).Abs() < tol.Limit {
+		return selected, nil
+	}
+	return nil, &AcceptValidationError{Rows: failed}
+}
+
+// sameContent compara a linha atual da ES com o que o cliente viu ao listar.
+func sameContent(es models.Transaction, e models.ExpectedEsRow) bool {
+	return strings.TrimSpace(es.IdParcela) == strings.TrimSpace(e.IdParcela) &&
+		strings.TrimSpace(es.Descricao) == strings.TrimSpace(e.Descricao) &&
+		es.Valor == e.Valor
+}
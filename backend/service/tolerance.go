@@ -0,0 +1,75 @@
+package service
+
+import (
+	"strings"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+)
+
+// toleranceFor escolhe a regra de tolerância para a transação da DIF. Entre as regras
+// configuradas que casam com Dono/Banco/Conta, vence a mais específica (mais campos
+// preenchidos); no empate, a primeira da lista. Sem nenhuma, vale a regra padrão.
+func (l *Logic) toleranceFor(dif models.Transaction) models.AppliedTolerance {
+	rule := l.cfg.MatchTolerance
+	name := "default"
+	best := -1
+
+	for _, r := range l.cfg.ToleranceRules {
+		if !ruleApplies(r, dif) {
+			continue
+		}
+		if s := ruleSpecificity(r); s > best {
+			best = s
+			rule = r
+			name = ruleName(r)
+		}
+	}
+
+	// Config zerada (ex.: montada à mão em testes) cairia em "diferença < 0", que nunca
+	// casa. Nesse caso vale o critério histórico de R$ 5,00.
+	if rule.Absolute <= 0 && rule.Percent <= 0 {
+		rule.Absolute = config.DefaultMatchTolerance
+	}
+
+	return models.AppliedTolerance{
+		Rule:     name,
+		Absolute: models.MoneyFromFloat(rule.Absolute),
+		Percent:  rule.Percent,
+		Limit:    max(models.MoneyFromFloat(rule.Absolute), dif.Valor.Abs().Percent(rule.Percent)),
+	}
+}
+
+func ruleApplies(r config.ToleranceRule, t models.Transaction) bool {
+	return (r.Dono == "" || r.Dono == t.Dono) &&
+		(r.Banco == "" || r.Banco == t.Banco) &&
+		(r.Conta == "" || r.Conta == t.Conta)
+}
+
+func ruleSpecificity(r config.ToleranceRule) int {
+	n := 0
+	for _, f := range []string{r.Dono, r.Banco, r.Conta} {
+		if f != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// ruleName identifica a regra na resposta da API, ex.: "dono=Alice, banco=Nubank".
+func ruleName(r config.ToleranceRule) string {
+	var parts []string
+	if r.Dono != "" {
+		parts = append(parts, "dono="+r.Dono)
+	}
+	if r.Banco != "" {
+		parts = append(parts, "banco="+r.Banco)
+	}
+	if r.Conta != "" {
+		parts = append(parts, "conta="+r.Conta)
+	}
+	if len(parts) == 0 {
+		return "default"
+	}
+	return strings.Join(parts, ", ")
+}
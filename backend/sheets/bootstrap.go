@@ -0,0 +1,331 @@
+package sheets
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"google.golang.org/api/sheets/v4"
+
+	"olivia-conciliation/backend/models"
+)
+
+// TabLayout is what Bootstrap expects of one tab.
+type TabLayout struct {
+	Name string
+	// Header is written to row 1 of an empty tab. Ignored when Formula is set.
+	Header []string
+	// Columns, when set, finds the transaction columns in a header by name. Row 1 is then
+	// checked with it instead of cell by cell against Header, so a tab with its columns in
+	// another order is not drift.
+	Columns func(header []interface{}) (models.ColumnMap, error)
+	// Table asks for one native table over the header columns, which AppendRows and
+	// DeleteRow need.
+	Table bool
+	// Formula, when set, goes in A1: the whole tab is computed by it (the DIF).
+	Formula string
+	// FormulaFor, when set, builds Formula from the columns Columns found in the other tabs,
+	// by tab name: the DIF formula depends on where each tab keeps IdParcela.
+	FormulaFor func(cols map[string]models.ColumnMap) string
+}
+
+// BootstrapReport lists what Bootstrap changed and where the spreadsheet differs from the
+// layout. Drift is only reported, never fixed: it may be data the user wants to keep.
+type BootstrapReport struct {
+	Created []string
+	Drift   []string
+}
+
+// DIFFormula is the DIF tab formula: the HOM rows, header included, whose IdParcela is in
+// neither ES nor REJ. cols says where each tab keeps its columns; a tab missing from it
+// has the default layout. HOM is copied from column A through its last transaction column.
+func DIFFormula(hom, es, rej string, cols map[string]models.ColumnMap) string {
+	colsOf := func(sheet string) models.ColumnMap {
+		if c, ok := cols[sheet]; ok {
+			return c
+		}
+		return models.DefaultColumns
+	}
+	id := func(sheet string, from int) string {
+		col := models.ColumnLetter(colsOf(sheet).IdParcela)
+		return fmt.Sprintf("%s!%s%d:%s", quoteSheetName(sheet), col, from, col)
+	}
+	last := models.ColumnLetter(slices.Max(colsOf(hom).Fields()))
+	return fmt.Sprintf(`=FILTER(%s!A:%s, (ROW(%s!A:A)=1) + (%s<>"") * ISNA(MATCH(%s, {%s; %s}, 0)))`,
+		quoteSheetName(hom), last, quoteSheetName(hom), id(hom, 1), id(hom, 1), id(es, 2), id(rej, 2))
+}
+
+// Bootstrap brings the spreadsheet to the given layout: it creates missing tabs, writes the
+// header (or formula) of tabs whose first row is empty and adds the native table of table
+// tabs that have none. Anything already there is left alone, so running it twice changes
+// nothing the second time; where it differs from the layout, it goes to the report.
+func (c *Client) Bootstrap(ctx context.Context, tabs []TabLayout) (*BootstrapReport, error) {
+	report := &BootstrapReport{}
+
+	byName, err := c.sheetsByName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var addSheets []*sheets.Request
+	for _, tab := range tabs {
+		if _, ok := byName[tab.Name]; !ok {
+			addSheets = append(addSheets, &sheets.Request{
+				AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: tab.Name}},
+			})
+			report.Created = append(report.Created, fmt.Sprintf("tab %q", tab.Name))
+		}
+	}
+	if len(addSheets) > 0 {
+		if err := c.batchUpdate(ctx, addSheets); err != nil {
+			return nil, fmt.Errorf("unable to create tabs: %w", err)
+		}
+		if byName, err = c.sheetsByName(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	firstRows, err := c.firstRows(ctx, tabs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Columns are resolved up front, so a formula can use those of tabs listed after it.
+	// An empty tab is resolved from the header about to be written.
+	cols := make(map[string]models.ColumnMap)
+	unresolved := false
+	for i, tab := range tabs {
+		if tab.Columns == nil {
+			continue
+		}
+		header := firstRows[i]
+		if len(header) == 0 {
+			header = headerRow(tab.Header)
+		}
+		found, err := tab.Columns(header)
+		if err != nil {
+			report.Drift = append(report.Drift, fmt.Sprintf("tab %q: %v", tab.Name, err))
+			unresolved = true
+			continue
+		}
+		cols[tab.Name] = found
+	}
+
+	var values []*sheets.ValueRange
+	var addTables []*sheets.Request
+	for i, tab := range tabs {
+		first := firstRows[i]
+		formula := tab.Formula
+		if tab.FormulaFor != nil {
+			if unresolved {
+				report.Drift = append(report.Drift, fmt.Sprintf("tab %q: formula left alone until the headers above are fixed", tab.Name))
+				continue
+			}
+			formula = tab.FormulaFor(cols)
+		}
+		if formula != "" {
+			switch {
+			case len(first) == 0:
+				values = append(values, &sheets.ValueRange{
+					Range:  quoteSheetName(tab.Name) + "!A1",
+					Values: [][]interface{}{{formula}},
+				})
+				report.Created = append(report.Created, fmt.Sprintf("formula in %q!A1", tab.Name))
+			case !sameFormula(fmt.Sprintf("%v", first[0]), formula):
+				report.Drift = append(report.Drift, fmt.Sprintf("tab %q: A1 is %v, expected %s", tab.Name, first[0], formula))
+			}
+			continue
+		}
+
+		if len(first) == 0 {
+			values = append(values, &sheets.ValueRange{
+				Range:  quoteSheetName(tab.Name) + "!A1",
+				Values: [][]interface{}{headerRow(tab.Header)},
+			})
+			report.Created = append(report.Created, fmt.Sprintf("header of %q", tab.Name))
+		} else if tab.Columns == nil {
+			report.Drift = append(report.Drift, headerDrift(tab, first)...)
+		}
+
+		if !tab.Table {
+			continue
+		}
+		s := byName[tab.Name]
+		switch len(s.Tables) {
+		case 0:
+			rows, err := c.FetchRows(ctx, tab.Name)
+			if err != nil {
+				return nil, err
+			}
+			table := newTable(s, tableName(tab.Name), columnNames(tab.Header, first), len(rows))
+			addTables = append(addTables, &sheets.Request{AddTable: &sheets.AddTableRequest{Table: table}})
+			report.Created = append(report.Created, fmt.Sprintf("native table of %q", tab.Name))
+		case 1:
+			rng := s.Tables[0].Range
+			if rng != nil && (rng.StartColumnIndex != 0 || rng.EndColumnIndex != int64(len(tab.Header))) {
+				report.Drift = append(report.Drift, fmt.Sprintf("tab %q: native table spans columns %s:%s, expected A:%s", tab.Name,
+					models.ColumnLetter(int(rng.StartColumnIndex)), models.ColumnLetter(int(rng.EndColumnIndex)-1), models.ColumnLetter(len(tab.Header)-1)))
+			}
+		default:
+			report.Drift = append(report.Drift, fmt.Sprintf("tab %q: has %d native tables, expected 1", tab.Name, len(s.Tables)))
+		}
+	}
+
+	// The header goes in before the table, so the table takes it as its header row.
+	if len(values) > 0 {
+		req := &sheets.BatchUpdateValuesRequest{ValueInputOption: "USER_ENTERED", Data: values}
+		err := c.do(ctx, idempotent, func(ctx context.Context) error {
+			_, err := c.srv.Spreadsheets.Values.BatchUpdate(c.spreadsheetID, req).Context(ctx).Do()
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to write headers: %w", err)
+		}
+	}
+	if len(addTables) > 0 {
+		if err := c.batchUpdate(ctx, addTables); err != nil {
+			return nil, fmt.Errorf("unable to create native tables: %w", err)
+		}
+	}
+	return report, nil
+}
+
+func (c *Client) sheetsByName(ctx context.Context) (map[string]*sheets.Sheet, error) {
+	var sp *sheets.Spreadsheet
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		sp, err = c.srv.Spreadsheets.Get(c.spreadsheetID).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get spreadsheet metadata: %w", err)
+	}
+	byName := make(map[string]*sheets.Sheet, len(sp.Sheets))
+	for _, s := range sp.Sheets {
+		byName[s.Properties.Title] = s
+	}
+	return byName, nil
+}
+
+// firstRows reads row 1 of every tab in one call, formulas as written rather than their
+// result.
+func (c *Client) firstRows(ctx context.Context, tabs []TabLayout) ([][]interface{}, error) {
+	ranges := make([]string, len(tabs))
+	for i, tab := range tabs {
+		ranges[i] = quoteSheetName(tab.Name) + "!1:1"
+	}
+	var resp *sheets.BatchGetValuesResponse
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		resp, err = c.srv.Spreadsheets.Values.BatchGet(c.spreadsheetID).Ranges(ranges...).
+			ValueRenderOption("FORMULA").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to read headers: %w", err)
+	}
+	if len(resp.ValueRanges) != len(tabs) {
+		return nil, fmt.Errorf("expected %d header ranges, got %d", len(tabs), len(resp.ValueRanges))
+	}
+	rows := make([][]interface{}, len(tabs))
+	for i, vr := range resp.ValueRanges {
+		if len(vr.Values) > 0 {
+			rows[i] = vr.Values[0]
+		}
+	}
+	return rows, nil
+}
+
+// batchUpdate sends structural requests (new tabs, tables). They are not retried on server
+// errors: a repeated AddSheet fails on the name the first one already took.
+func (c *Client) batchUpdate(ctx context.Context, reqs []*sheets.Request) error {
+	req := &sheets.BatchUpdateSpreadsheetRequest{Requests: reqs}
+	return c.do(ctx, nonIdempotent, func(ctx context.Context) error {
+		_, err := c.srv.Spreadsheets.BatchUpdate(c.spreadsheetID, req).Context(ctx).Do()
+		return err
+	})
+}
+
+// columnNames are the header cells the table will show: what row 1 already has, so an
+// existing header is kept even when it drifts, and the expected header where it is empty.
+func columnNames(header []string, first []interface{}) []string {
+	names := make([]string, len(header))
+	for i, h := range header {
+		names[i] = h
+		if i < len(first) {
+			if v := strings.TrimSpace(fmt.Sprintf("%v", first[i])); v != "" {
+				names[i] = v
+			}
+		}
+	}
+	return names
+}
+
+// newTable covers the named columns from row 1 down to the last of dataRows (header
+// included), with at least one data row so the table is not empty.
+func newTable(s *sheets.Sheet, name string, columns []string, dataRows int) *sheets.Table {
+	rows := int64(max(dataRows, 2))
+	cols := make([]*sheets.TableColumnProperties, len(columns))
+	for i, h := range columns {
+		cols[i] = &sheets.TableColumnProperties{ColumnIndex: int64(i), ColumnName: h, ForceSendFields: []string{"ColumnIndex"}}
+	}
+	return &sheets.Table{
+		Name: name,
+		Range: &sheets.GridRange{
+			SheetId:          s.Properties.SheetId,
+			StartRowIndex:    0,
+			EndRowIndex:      rows,
+			StartColumnIndex: 0,
+			EndColumnIndex:   int64(len(columns)),
+			ForceSendFields:  []string{"SheetId", "StartRowIndex", "StartColumnIndex"},
+		},
+		ColumnProperties: cols,
+	}
+}
+
+// tableName derives a table name from the tab title; table names only take letters,
+// digits and underscores.
+func tableName(tab string) string {
+	var b strings.Builder
+	for _, r := range tab {
+		switch {
+		case r == ' ' || r == '-':
+			b.WriteRune('_')
+		case r == '_' || r >= '0' && r <= '9' || r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r > 127:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func headerRow(header []string) []interface{} {
+	row := make([]interface{}, len(header))
+	for i, h := range header {
+		row[i] = h
+	}
+	return row
+}
+
+// headerDrift compares a tab's first row with its expected header. Column A is left out:
+// the backend never reads it.
+func headerDrift(tab TabLayout, first []interface{}) []string {
+	var drift []string
+	for i := 1; i < len(tab.Header); i++ {
+		got := ""
+		if i < len(first) {
+			got = strings.TrimSpace(fmt.Sprintf("%v", first[i]))
+		}
+		if !strings.EqualFold(got, tab.Header[i]) {
+			drift = append(drift, fmt.Sprintf("tab %q: header of column %s is %q, expected %q",
+				tab.Name, models.ColumnLetter(i), got, tab.Header[i]))
+		}
+	}
+	return drift
+}
+
+// sameFormula compares formulas ignoring spacing and the quotes Sheets drops from simple
+// sheet names when it stores a formula.
+func sameFormula(a, b string) bool {
+	norm := func(s string) string {
+		return strings.ToUpper(strings.NewReplacer(" ", "", "'", "").Replace(s))
+	}
+	return norm(a) == norm(b)
+}
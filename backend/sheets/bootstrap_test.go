@@ -0,0 +1,289 @@
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+
+	"olivia-conciliation/backend/models"
+)
+
+// fakeSpreadsheet answers the calls Bootstrap makes from an in-memory layout and records
+// every write.
+type fakeSpreadsheet struct {
+	tabs      map[string]*sheets.Sheet
+	firstRows map[string][]interface{}
+	writes    []string               // "values:batchUpdate" or the structural request kinds
+	values    map[string]interface{} // range -> first cell written there
+}
+
+func (f *fakeSpreadsheet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := r.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/values:batchGet"):
+		var ranges []map[string]interface{}
+		for _, rng := range r.URL.Query()["ranges"] {
+			name := strings.Trim(strings.TrimSuffix(rng, "!1:1"), "'")
+			vr := map[string]interface{}{"range": rng}
+			if row := f.firstRows[name]; len(row) > 0 {
+				vr["values"] = [][]interface{}{row}
+			}
+			ranges = append(ranges, vr)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"valueRanges": ranges})
+	case strings.HasSuffix(path, "/values:batchUpdate"):
+		f.writes = append(f.writes, "values:batchUpdate")
+		var req sheets.BatchUpdateValuesRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if f.values == nil {
+			f.values = make(map[string]interface{})
+		}
+		for _, vr := range req.Data {
+			f.values[vr.Range] = vr.Values[0][0]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	case strings.HasSuffix(path, ":batchUpdate"):
+		var req sheets.BatchUpdateSpreadsheetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		for _, q := range req.Requests {
+			switch {
+			case q.AddSheet != nil:
+				title := q.AddSheet.Properties.Title
+				f.tabs[title] = &sheets.Sheet{Properties: &sheets.SheetProperties{Title: title, SheetId: int64(len(f.tabs) + 1)}}
+				f.writes = append(f.writes, "addSheet "+title)
+			case q.AddTable != nil:
+				f.writes = append(f.writes, "addTable "+q.AddTable.Table.Name)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	case strings.Contains(path, "/values/"):
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	default:
+		var list []*sheets.Sheet
+		for _, s := range f.tabs {
+			list = append(list, s)
+		}
+		json.NewEncoder(w).Encode(&sheets.Spreadsheet{Sheets: list})
+	}
+}
+
+func newBootstrapClient(t *testing.T, fake *fakeSpreadsheet) *Client {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	c, err := newClient(context.Background(), "sheet-id", testPolicy(), nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+	return c
+}
+
+var testHeader = []string{"Id", "Data", "Descrição"}
+
+func testLayout() []TabLayout {
+	return []TabLayout{
+		{Name: "HOM", Header: testHeader},
+		{Name: "ES", Header: testHeader, Table: true},
+		{Name: "DIF", Formula: DIFFormula("HOM", "ES", "REJ", nil)},
+	}
+}
+
+func TestBootstrap_CreatesWhatIsMissing(t *testing.T) {
+	fake := &fakeSpreadsheet{
+		tabs:      map[string]*sheets.Sheet{"HOM": {Properties: &sheets.SheetProperties{Title: "HOM"}}},
+		firstRows: map[string][]interface{}{"HOM": {"Id", "Data", "Descrição"}},
+	}
+	c := newBootstrapClient(t, fake)
+
+	report, err := c.Bootstrap(context.Background(), testLayout())
+	if err != nil {
+		t.Fatalf("Bootstrap() error: %v", err)
+	}
+	want := []string{"addSheet ES", "addSheet DIF", "values:batchUpdate", "addTable ES"}
+	if strings.Join(fake.writes, ",") != strings.Join(want, ",") {
+		t.Errorf("expected writes %v, got %v", want, fake.writes)
+	}
+	if len(report.Drift) != 0 || len(report.Created) != 5 {
+		t.Errorf("unexpected report: %+v", report)
+	}
+}
+
+func TestBootstrap_ReportsDriftWithoutWriting(t *testing.T) {
+	twoTables := []*sheets.Table{{TableId: "a"}, {TableId: "b"}}
+	fake := &fakeSpreadsheet{
+		tabs: map[string]*sheets.Sheet{
+			"HOM": {Properties: &sheets.SheetProperties{Title: "HOM"}},
+			"ES":  {Properties: &sheets.SheetProperties{Title: "ES"}, Tables: twoTables},
+			"DIF": {Properties: &sheets.SheetProperties{Title: "DIF"}},
+		},
+		firstRows: map[string][]interface{}{
+			"HOM": {"Id", "Data", "Descrição"},
+			"ES":  {"Id", "Quando", "Descrição"},
+			"DIF": {"=HOM!A:C"},
+		},
+	}
+	c := newBootstrapClient(t, fake)
+
+	report, err := c.Bootstrap(context.Background(), testLayout())
+	if err != nil {
+		t.Fatalf("Bootstrap() error: %v", err)
+	}
+	if len(fake.writes) != 0 || len(report.Created) != 0 {
+		t.Errorf("expected no writes, got %v (report %+v)", fake.writes, report)
+	}
+	if len(report.Drift) != 3 {
+		t.Errorf("expected drift in the ES header, the ES tables and the DIF formula, got %v", report.Drift)
+	}
+}
+
+func TestBootstrap_MatchingSpreadsheetIsLeftAlone(t *testing.T) {
+	fake := &fakeSpreadsheet{
+		tabs: map[string]*sheets.Sheet{
+			"HOM": {Properties: &sheets.SheetProperties{Title: "HOM"}},
+			"ES": {Properties: &sheets.SheetProperties{Title: "ES"}, Tables: []*sheets.Table{
+				{TableId: "t", Range: &sheets.GridRange{EndColumnIndex: 3}},
+			}},
+			"DIF": {Properties: &sheets.SheetProperties{Title: "DIF"}},
+		},
+		firstRows: map[string][]interface{}{
+			"HOM": {"Id", "Data", "Descrição"},
+			"ES":  {"Id", "data", "Descrição "},
+			// Sheets drops the quotes around simple sheet names when it stores the formula.
+			"DIF": {strings.ReplaceAll(DIFFormula("HOM", "ES", "REJ", nil), "'", "")},
+		},
+	}
+	c := newBootstrapClient(t, fake)
+
+	report, err := c.Bootstrap(context.Background(), testLayout())
+	if err != nil {
+		t.Fatalf("Bootstrap() error: %v", err)
+	}
+	if len(fake.writes) != 0 || len(report.Created) != 0 || len(report.Drift) != 0 {
+		t.Errorf("expected nothing to do, got writes %v and report %+v", fake.writes, report)
+	}
+}
+
+// columnsByName finds the default transaction headers by name, standing in for the
+// service Parser.
+func columnsByName(header []interface{}) (models.ColumnMap, error) {
+	at := make([]int, len(models.TransactionHeader))
+	for f, name := range models.TransactionHeader {
+		at[f] = -1
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(fmt.Sprintf("%v", h)), name) {
+				at[f] = i
+			}
+		}
+		if at[f] < 0 && f > 0 {
+			return models.ColumnMap{}, fmt.Errorf("%q is missing", name)
+		}
+	}
+	return models.ColumnMap{
+		Data: at[models.ColumnData], Descricao: at[models.ColumnDescricao], Valor: at[models.ColumnValor],
+		Categoria: at[models.ColumnCategoria], Dono: at[models.ColumnDono], Banco: at[models.ColumnBanco],
+		Conta: at[models.ColumnConta], Recorrente: at[models.ColumnRecorrente], IdParcela: at[models.ColumnIdParcela],
+	}, nil
+}
+
+func TestBootstrap_AcceptsReorderedColumnsAndBuildsDIFFromThem(t *testing.T) {
+	reordered := []interface{}{"Id", "IdParcela", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente"}
+	defaults := headerRow(models.TransactionHeader)
+	fake := &fakeSpreadsheet{
+		tabs: map[string]*sheets.Sheet{
+			"HOM": {Properties: &sheets.SheetProperties{Title: "HOM"}},
+			"ES":  {Properties: &sheets.SheetProperties{Title: "ES"}},
+			"REJ": {Properties: &sheets.SheetProperties{Title: "REJ"}},
+			"DIF": {Properties: &sheets.SheetProperties{Title: "DIF"}},
+		},
+		firstRows: map[string][]interface{}{"HOM": reordered, "ES": defaults, "REJ": reordered},
+	}
+	c := newBootstrapClient(t, fake)
+
+	layout := []TabLayout{
+		{Name: "DIF", FormulaFor: func(cols map[string]models.ColumnMap) string {
+			return DIFFormula("HOM", "ES", "REJ", cols)
+		}},
+		{Name: "HOM", Header: models.TransactionHeader, Columns: columnsByName},
+		{Name: "ES", Header: models.TransactionHeader, Columns: columnsByName},
+		{Name: "REJ", Header: models.TransactionHeader, Columns: columnsByName},
+	}
+	report, err := c.Bootstrap(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Bootstrap() error: %v", err)
+	}
+	if len(report.Drift) != 0 {
+		t.Errorf("expected no drift for reordered columns, got %v", report.Drift)
+	}
+	want := `=FILTER('HOM'!A:J, (ROW('HOM'!A:A)=1) + ('HOM'!B1:B<>"") * ` +
+		`ISNA(MATCH('HOM'!B1:B, {'ES'!J2:J; 'REJ'!B2:B}, 0)))`
+	if got := fake.values["'DIF'!A1"]; got != want {
+		t.Errorf("DIF formula =\n%v\nwant\n%s", got, want)
+	}
+}
+
+func TestBootstrap_LeavesDIFAloneWhileAHeaderIsMissing(t *testing.T) {
+	fake := &fakeSpreadsheet{
+		tabs: map[string]*sheets.Sheet{
+			"HOM": {Properties: &sheets.SheetProperties{Title: "HOM"}},
+			"DIF": {Properties: &sheets.SheetProperties{Title: "DIF"}},
+		},
+		firstRows: map[string][]interface{}{"HOM": {"Id", "Data", "Descrição"}},
+	}
+	c := newBootstrapClient(t, fake)
+
+	layout := []TabLayout{
+		{Name: "HOM", Header: models.TransactionHeader, Columns: columnsByName},
+		{Name: "DIF", FormulaFor: func(cols map[string]models.ColumnMap) string {
+			return DIFFormula("HOM", "ES", "REJ", cols)
+		}},
+	}
+	report, err := c.Bootstrap(context.Background(), layout)
+	if err != nil {
+		t.Fatalf("Bootstrap() error: %v", err)
+	}
+	if len(fake.writes) != 0 {
+		t.Errorf("expected no writes, got %v", fake.writes)
+	}
+	if len(report.Drift) != 2 {
+		t.Errorf("expected drift in the HOM header and the DIF formula, got %v", report.Drift)
+	}
+}
+
+func TestDIFFormula(t *testing.T) {
+	got := DIFFormula("Homologação", "Entradas e Saídas", "Rejeitados", nil)
+	want := `=FILTER('Homologação'!A:J, (ROW('Homologação'!A:A)=1) + ('Homologação'!J1:J<>"") * ` +
+		`ISNA(MATCH('Homologação'!J1:J, {'Entradas e Saídas'!J2:J; 'Rejeitados'!J2:J}, 0)))`
+	if got != want {
+		t.Errorf("DIFFormula() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCheckLayout_ReportsMissingTabsAndTables(t *testing.T) {
+	fake := &fakeSpreadsheet{tabs: map[string]*sheets.Sheet{
+		"ES":  {Properties: &sheets.SheetProperties{Title: "ES"}, Tables: []*sheets.Table{{TableId: "table-other"}}},
+		"DIF": {Properties: &sheets.SheetProperties{Title: "DIF"}},
+	}}
+	c := newBootstrapClient(t, fake)
+	c.tableIDs["ES"] = "table-es"
+
+	checks := c.CheckLayout(context.Background(), "ES", "DIF", "HOM")
+
+	want := map[string]bool{"credentials": true, "tab:ES": true, "tab:DIF": true, "tab:HOM": false, "table:ES": false}
+	if len(checks) != len(want) {
+		t.Fatalf("expected %d checks, got %+v", len(want), checks)
+	}
+	for _, ch := range checks {
+		if ok, known := want[ch.Name]; !known || ok != ch.OK {
+			t.Errorf("unexpected check %+v", ch)
+		}
+	}
+}
@@ -3,22 +3,42 @@ package sheets
 import (
 	"context"
 	"fmt"
+	"log"
 	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
 	"google.golang.org/api/sheets/v4"
+
+	"olivia-conciliation/backend/models"
 )
 
 type Client struct {
 	srv           *sheets.Service
 	spreadsheetID string
 	tableIDs      map[string]string
+
+	// rangesMu guards tableRanges, which tableRange refreshes while requests are served.
+	rangesMu    sync.Mutex
+	tableRanges map[string]*sheets.GridRange
+
+	policy  RetryPolicy
+	limiter *tokenBucket
+	sleep   func(context.Context, time.Duration) error
+
+	// formatted makes reads return cells as the sheet displays them; see UseFormattedValues.
+	formatted bool
 }
 
 // NewClient creates a Sheets client and caches the native table ID for each sheet
 // listed in tableSheets. Fails if any sheet has zero or more than one native table.
-func NewClient(ctx context.Context, spreadsheetID string, tableSheets ...string) (*Client, error) {
+// Every API call goes through policy: rate-limited and retried on transient errors.
+func NewClient(ctx context.Context, spreadsheetID string, policy RetryPolicy, tableSheets ...string) (*Client, error) {
 	credsPath := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
 	if credsPath == "" {
 		credsPath = "credentials.json"
@@ -34,20 +54,30 @@ func NewClient(ctx context.Context, spreadsheetID string, tableSheets ...string)
 		return nil, fmt.Errorf("unable to parse client secret file to config: %v", err)
 	}
 
+	return newClient(ctx, spreadsheetID, policy, tableSheets, option.WithHTTPClient(config.Client(ctx)))
+}
+
+// newClient builds the client on top of the given options; tests point it at an
+// httptest server with option.WithEndpoint.
+func newClient(ctx context.Context, spreadsheetID string, policy RetryPolicy, tableSheets []string, opts ...option.ClientOption) (*Client, error) {
 	c := &Client{
 		srv:           nil,
 		spreadsheetID: spreadsheetID,
 		tableIDs:      make(map[string]string),
+		tableRanges:   make(map[string]*sheets.GridRange),
+		policy:        policy,
+		limiter:       newTokenBucket(policy.RequestsPerMinute),
+		sleep:         sleepCtx,
 	}
 
-	svc, err := sheets.NewService(ctx, option.WithHTTPClient(config.Client(ctx)))
+	svc, err := sheets.NewService(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("unable to retrieve Sheets client: %v", err)
 	}
 	c.srv = svc
 
 	if len(tableSheets) > 0 {
-		if err := c.initTableIDs(tableSheets); err != nil {
+		if err := c.initTableIDs(ctx, tableSheets); err != nil {
 			return nil, err
 		}
 	}
@@ -55,10 +85,14 @@ func NewClient(ctx context.Context, spreadsheetID string, tableSheets ...string)
 	return c, nil
 }
 
-func (c *Client) initTableIDs(sheetNames []string) error {
-	sp, err := c.srv.Spreadsheets.Get(c.spreadsheetID).Do()
+func (c *Client) initTableIDs(ctx context.Context, sheetNames []string) error {
+	var sp *sheets.Spreadsheet
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		sp, err = c.srv.Spreadsheets.Get(c.spreadsheetID).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("unable to get spreadsheet metadata: %v", err)
+		return fmt.Errorf("unable to get spreadsheet metadata: %w", err)
 	}
 
 	byName := make(map[string]*sheets.Sheet, len(sp.Sheets))
@@ -75,59 +109,176 @@ func (c *Client) initTableIDs(sheetNames []string) error {
 			return fmt.Errorf("sheet %q must have exactly one native table, found %d", name, len(s.Tables))
 		}
 		c.tableIDs[name] = s.Tables[0].TableId
+		c.tableRanges[name] = s.Tables[0].Range
 	}
 
 	return nil
 }
 
-func (c *Client) FetchRows(sheetName string) ([][]interface{}, error) {
-	resp, err := c.srv.Spreadsheets.Values.Get(c.spreadsheetID, sheetName).Do()
+// UseFormattedValues switches reads back to FORMATTED_VALUE, the cells as the sheet
+// displays them. By default reads use UNFORMATTED_VALUE with SERIAL_NUMBER dates: amounts
+// arrive as float64 and dates as serial numbers, whatever the locale or number format of
+// the spreadsheet.
+func (c *Client) UseFormattedValues(on bool) {
+	c.formatted = on
+}
+
+// renderOptions returns the value and date-time render options of FetchRows and FetchSheets.
+func (c *Client) renderOptions() (value, dateTime string) {
+	if c.formatted {
+		return "FORMATTED_VALUE", "FORMATTED_STRING"
+	}
+	return "UNFORMATTED_VALUE", "SERIAL_NUMBER"
+}
+
+func (c *Client) FetchRows(ctx context.Context, sheetName string) ([][]interface{}, error) {
+	value, dateTime := c.renderOptions()
+	var resp *sheets.ValueRange
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		resp, err = c.srv.Spreadsheets.Values.Get(c.spreadsheetID, sheetName).
+			ValueRenderOption(value).DateTimeRenderOption(dateTime).Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("unable to retrieve data from sheet: %v", err)
+		return nil, fmt.Errorf("unable to retrieve data from sheet: %w", err)
 	}
 	return resp.Values, nil
 }
 
-func (c *Client) WriteCell(sheetName string, rowIndex int, colIndex int, value string) error {
-	colLetter := ""
-	tempIdx := colIndex
-	for {
-		colLetter = string(rune('A'+(tempIdx%26))) + colLetter
-		tempIdx = (tempIdx / 26) - 1
-		if tempIdx < 0 {
-			break
-		}
+// FetchSheets reads several whole sheets with a single Values.BatchGet call. The range is
+// the bare sheet name, not a column span: the service finds each column by its header,
+// so a column inserted before IdParcela must not push it out of the read.
+func (c *Client) FetchSheets(ctx context.Context, sheetNames ...string) (map[string][][]interface{}, error) {
+	ranges := make([]string, len(sheetNames))
+	for i, name := range sheetNames {
+		ranges[i] = quoteSheetName(name)
+	}
+
+	value, dateTime := c.renderOptions()
+	var resp *sheets.BatchGetValuesResponse
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		resp, err = c.srv.Spreadsheets.Values.BatchGet(c.spreadsheetID).Ranges(ranges...).
+			ValueRenderOption(value).DateTimeRenderOption(dateTime).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve data from sheets %q: %w", sheetNames, err)
+	}
+	if len(resp.ValueRanges) != len(sheetNames) {
+		return nil, fmt.Errorf("expected %d ranges from sheets %q, got %d", len(sheetNames), sheetNames, len(resp.ValueRanges))
 	}
 
-	rangeStr := fmt.Sprintf("%s!%s%d", sheetName, colLetter, rowIndex+1)
+	// BatchGet devolve os intervalos na ordem pedida.
+	out := make(map[string][][]interface{}, len(sheetNames))
+	for i, name := range sheetNames {
+		out[name] = resp.ValueRanges[i].Values
+	}
+	return out, nil
+}
+
+// WriteCell writes value as is (RAW). A models.Date goes as its serial number, so the cell
+// holds a date rather than text; Money goes as a number in reais.
+func (c *Client) WriteCell(ctx context.Context, sheetName string, rowIndex int, colIndex int, value interface{}) error {
 	val := &sheets.ValueRange{
-		Values: [][]interface{}{{value}},
+		Values: [][]interface{}{{rawValue(value)}},
 	}
 
-	_, err := c.srv.Spreadsheets.Values.Update(c.spreadsheetID, rangeStr, val).ValueInputOption("RAW").Do()
+	err := c.do(ctx, idempotent, func(ctx context.Context) error {
+		_, err := c.srv.Spreadsheets.Values.Update(c.spreadsheetID, cellRange(sheetName, rowIndex, colIndex), val).ValueInputOption("RAW").Context(ctx).Do()
+		return err
+	})
 	if err != nil {
-		return fmt.Errorf("unable to update data: %v", err)
+		return fmt.Errorf("unable to update data: %w", err)
 	}
 	return nil
 }
 
-func (c *Client) AppendRow(sheetName string, values []interface{}) error {
+// WriteCells writes every cell in a single Values.BatchUpdate call. The API applies the
+// batch atomically, so a failure leaves none of the cells written.
+func (c *Client) WriteCells(ctx context.Context, sheetName string, cells []models.CellUpdate) error {
+	if len(cells) == 0 {
+		return nil
+	}
+
+	data := make([]*sheets.ValueRange, len(cells))
+	for i, cell := range cells {
+		data[i] = &sheets.ValueRange{
+			Range:  cellRange(sheetName, cell.Row, cell.Col),
+			Values: [][]interface{}{{cell.Value}},
+		}
+	}
+
+	req := &sheets.BatchUpdateValuesRequest{ValueInputOption: "RAW", Data: data}
+	err := c.do(ctx, idempotent, func(ctx context.Context) error {
+		_, err := c.srv.Spreadsheets.Values.BatchUpdate(c.spreadsheetID, req).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("unable to update %d cells in sheet %q: %w", len(cells), sheetName, err)
+	}
+	return nil
+}
+
+func (c *Client) AppendRow(ctx context.Context, sheetName string, values []interface{}) error {
+	return c.AppendRows(ctx, sheetName, [][]interface{}{values})
+}
+
+// rawValue converts a cell for the Values API with RAW input, where a number stays a number.
+func rawValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case models.Date:
+		if v.Valid() {
+			return v.Serial()
+		}
+		return v.Raw
+	case models.Money:
+		return v.Reais()
+	}
+	return v
+}
+
+// userEnteredValue converts an outgoing cell. Money goes as a number, in reais, and the
+// typed values of an unformatted read (float64, such as a serial date, and bool) keep
+// their type, so the sheet's formats apply to them; everything else goes as text.
+func userEnteredValue(v interface{}) *sheets.ExtendedValue {
+	switch v := v.(type) {
+	case models.Money:
+		reais := v.Reais()
+		return &sheets.ExtendedValue{NumberValue: &reais, ForceSendFields: []string{"NumberValue"}}
+	case float64:
+		return &sheets.ExtendedValue{NumberValue: &v, ForceSendFields: []string{"NumberValue"}}
+	case bool:
+		return &sheets.ExtendedValue{BoolValue: &v, ForceSendFields: []string{"BoolValue"}}
+	}
+	str := ""
+	if v != nil {
+		str = fmt.Sprintf("%v", v)
+	}
+	ev := &sheets.ExtendedValue{StringValue: &str}
+	if str == "" {
+		ev.ForceSendFields = []string{"StringValue"}
+	}
+	return ev
+}
+
+// AppendRows appends all rows to the sheet's native table with a single AppendCells
+// request, so either every row lands or none does.
+func (c *Client) AppendRows(ctx context.Context, sheetName string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
 	tableID, ok := c.tableIDs[sheetName]
 	if !ok {
 		return fmt.Errorf("no native table cached for sheet %q", sheetName)
 	}
 
-	cellData := make([]*sheets.CellData, len(values))
-	for i, v := range values {
-		str := ""
-		if v != nil {
-			str = fmt.Sprintf("%v", v)
+	rowData := make([]*sheets.RowData, len(rows))
+	for r, values := range rows {
+		cellData := make([]*sheets.CellData, len(values))
+		for i, v := range values {
+			cellData[i] = &sheets.CellData{UserEnteredValue: userEnteredValue(v)}
 		}
-		ev := &sheets.ExtendedValue{StringValue: &str}
-		if str == "" {
-			ev.ForceSendFields = []string{"StringValue"}
-		}
-		cellData[i] = &sheets.CellData{UserEnteredValue: ev}
+		rowData[r] = &sheets.RowData{Values: cellData}
 	}
 
 	req := &sheets.BatchUpdateSpreadsheetRequest{
@@ -135,16 +286,239 @@ func (c *Client) AppendRow(sheetName string, values []interface{}) error {
 			{
 				AppendCells: &sheets.AppendCellsRequest{
 					TableId: tableID,
-					Rows:    []*sheets.RowData{{Values: cellData}},
+					Rows:    rowData,
 					Fields:  "userEnteredValue",
 				},
 			},
 		},
 	}
 
-	_, err := c.srv.Spreadsheets.BatchUpdate(c.spreadsheetID, req).Do()
+	err := c.do(ctx, nonIdempotent, func(ctx context.Context) error {
+		_, err := c.srv.Spreadsheets.BatchUpdate(c.spreadsheetID, req).Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		if len(rows) == 1 {
+			return fmt.Errorf("unable to append row to sheet %q: %w", sheetName, err)
+		}
+		return fmt.Errorf("unable to append %d rows to sheet %q: %w", len(rows), sheetName, err)
+	}
+	return nil
+}
+
+// DeleteRow removes row rowIndex (0-based, as in FetchRows) from the sheet's native table.
+// Only the table columns are deleted and the rows below shift up, the same as deleting
+// the row from the table UI; cells outside the table are left alone.
+func (c *Client) DeleteRow(ctx context.Context, sheetName string, rowIndex int) error {
+	return c.DeleteRows(ctx, sheetName, []int{rowIndex})
+}
+
+// DeleteRows removes every row in rowIndexes with a single BatchUpdate, which the API
+// applies atomically. The DeleteRange requests go bottom-up, so each index still points at
+// the row it named before the first deletion.
+func (c *Client) DeleteRows(ctx context.Context, sheetName string, rowIndexes []int) error {
+	if len(rowIndexes) == 0 {
+		return nil
+	}
+	desc := slices.Clone(rowIndexes)
+	slices.Sort(desc)
+	desc = slices.Compact(desc)
+	slices.Reverse(desc)
+
+	var rng *sheets.GridRange
+	requests := make([]*sheets.Request, len(desc))
+	for i, rowIndex := range desc {
+		var err error
+		if rng, err = c.tableRange(ctx, sheetName, rowIndex); err != nil {
+			return err
+		}
+		requests[i] = &sheets.Request{
+			DeleteRange: &sheets.DeleteRangeRequest{
+				Range: &sheets.GridRange{
+					SheetId:          rng.SheetId,
+					StartRowIndex:    int64(rowIndex),
+					EndRowIndex:      int64(rowIndex) + 1,
+					StartColumnIndex: rng.StartColumnIndex,
+					EndColumnIndex:   rng.EndColumnIndex,
+					ForceSendFields:  []string{"SheetId", "StartColumnIndex"},
+				},
+				ShiftDimension: "ROWS",
+			},
+		}
+	}
+
+	req := &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}
+	err := c.do(ctx, nonIdempotent, func(ctx context.Context) error {
+		_, err := c.srv.Spreadsheets.BatchUpdate(c.spreadsheetID, req).Context(ctx).Do()
+		return err
+	})
+	c.shrinkTableRange(sheetName, rng, len(desc), err)
 	if err != nil {
-		return fmt.Errorf("unable to append row to sheet %q: %v", sheetName, err)
+		if len(desc) == 1 {
+			return fmt.Errorf("unable to delete row %d from sheet %q: %w", desc[0], sheetName, err)
+		}
+		return fmt.Errorf("unable to delete %d rows from sheet %q: %w", len(desc), sheetName, err)
 	}
 	return nil
 }
+
+// shrinkTableRange updates the cached range after a delete of n rows from rng: it loses
+// those rows when the delete went through. A failed delete may still have been applied,
+// so the range is dropped instead and re-read by the next tableRange.
+func (c *Client) shrinkTableRange(sheetName string, rng *sheets.GridRange, n int, err error) {
+	c.rangesMu.Lock()
+	defer c.rangesMu.Unlock()
+	if c.tableRanges[sheetName] != rng {
+		return // refreshed meanwhile
+	}
+	if err != nil {
+		delete(c.tableRanges, sheetName)
+		return
+	}
+	shrunk := *rng
+	shrunk.EndRowIndex -= int64(n)
+	c.tableRanges[sheetName] = &shrunk
+}
+
+// tableRange returns the range of the native table of sheetName once rowIndex is known to
+// be one of its data rows: below the header and above the end of the table. The range is
+// cached at startup and the table grows with every append, from the backend or typed in
+// the sheet, so a row past the cached end (or a range dropped after a failed delete)
+// refreshes it before being refused.
+func (c *Client) tableRange(ctx context.Context, sheetName string, rowIndex int) (*sheets.GridRange, error) {
+	if _, ok := c.tableIDs[sheetName]; !ok {
+		return nil, fmt.Errorf("no native table cached for sheet %q", sheetName)
+	}
+	c.rangesMu.Lock()
+	rng := c.tableRanges[sheetName]
+	c.rangesMu.Unlock()
+	if rng == nil || int64(rowIndex) >= rng.EndRowIndex {
+		fresh, err := c.fetchTableRange(ctx, sheetName)
+		if err != nil {
+			return nil, err
+		}
+		rng = fresh
+	}
+	if int64(rowIndex) <= rng.StartRowIndex || int64(rowIndex) >= rng.EndRowIndex {
+		return nil, fmt.Errorf("row %d is not a data row of the table in sheet %q (rows %d to %d)",
+			rowIndex, sheetName, rng.StartRowIndex+1, rng.EndRowIndex-1)
+	}
+	return rng, nil
+}
+
+// fetchTableRange reads the current range of the native table cached for sheetName and
+// updates the cache.
+func (c *Client) fetchTableRange(ctx context.Context, sheetName string) (*sheets.GridRange, error) {
+	var sp *sheets.Spreadsheet
+	err := c.do(ctx, idempotent, func(ctx context.Context) (err error) {
+		sp, err = c.srv.Spreadsheets.Get(c.spreadsheetID).Fields("sheets(properties(title),tables(tableId,range))").Context(ctx).Do()
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get spreadsheet metadata: %w", err)
+	}
+	for _, s := range sp.Sheets {
+		if s.Properties == nil || s.Properties.Title != sheetName {
+			continue
+		}
+		for _, t := range s.Tables {
+			if t.TableId == c.tableIDs[sheetName] && t.Range != nil {
+				c.rangesMu.Lock()
+				c.tableRanges[sheetName] = t.Range
+				c.rangesMu.Unlock()
+				return t.Range, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("native table %s of sheet %q no longer exists", c.tableIDs[sheetName], sheetName)
+}
+
+// quoteSheetName quotes a sheet name for A1 notation, e.g. Entradas e Saídas -> 'Entradas e Saídas'.
+func quoteSheetName(name string) string {
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// cellRange builds the A1 reference of a single cell, e.g. ("ES", 2, 9) -> "ES!J3".
+func cellRange(sheetName string, rowIndex, colIndex int) string {
+	return fmt.Sprintf("%s!%s%d", sheetName, models.ColumnLetter(colIndex), rowIndex+1)
+}
+
+// do runs call under the shared rate limit, retrying transient failures with jittered
+// exponential backoff (or the server's Retry-After) up to policy.MaxAttempts tries. Each
+// try gets its own policy.AttemptTimeout; ctx bounds the whole thing, waits included.
+func (c *Client) do(ctx context.Context, class retryClass, call func(ctx context.Context) error) error {
+	attempts := max(c.policy.MaxAttempts, 1)
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if err := c.limiter.take(ctx); err != nil {
+			return err
+		}
+		if err = c.attempt(ctx, call); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		retry, wait := retryable(err, class)
+		if !retry || attempt == attempts-1 {
+			break
+		}
+		if wait == 0 {
+			wait = c.policy.backoff(attempt)
+		}
+		log.Printf("sheets: attempt %d/%d failed, retrying in %v: %v", attempt+1, attempts, wait, err)
+		if err := c.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+	return err
+}
+
+func (c *Client) attempt(ctx context.Context, call func(ctx context.Context) error) error {
+	if c.policy.AttemptTimeout <= 0 {
+		return call(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.policy.AttemptTimeout)
+	defer cancel()
+	return call(ctx)
+}
+
+// CheckLayout reports, for /readyz, whether the credentials still open the spreadsheet,
+// whether each of sheetNames is still there and whether the native tables cached at
+// startup still exist. The header checks are left to the service, which knows the columns.
+func (c *Client) CheckLayout(ctx context.Context, sheetNames ...string) []models.HealthCheck {
+	byName, err := c.sheetsByName(ctx)
+	if err != nil {
+		return []models.HealthCheck{{Name: "credentials", Detail: err.Error()}}
+	}
+	checks := []models.HealthCheck{{Name: "credentials", OK: true}}
+
+	for _, name := range sheetNames {
+		check := models.HealthCheck{Name: "tab:" + name, OK: byName[name] != nil}
+		if !check.OK {
+			check.Detail = "sheet not found in spreadsheet"
+		}
+		checks = append(checks, check)
+	}
+
+	names := make([]string, 0, len(c.tableIDs))
+	for name := range c.tableIDs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		check := models.HealthCheck{Name: "table:" + name}
+		if s := byName[name]; s != nil {
+			for _, t := range s.Tables {
+				if t.TableId == c.tableIDs[name] {
+					check.OK = true
+				}
+			}
+		}
+		if !check.OK {
+			check.Detail = fmt.Sprintf("native table %s cached at startup no longer exists; restart after fixing the sheet", c.tableIDs[name])
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
@@ -0,0 +1,407 @@
+package sheets
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/api/option"
+	"google.golang.org/api/sheets/v4"
+
+	"olivia-conciliation/backend/models"
+)
+
+// fakeSheets is an httptest stand-in for the Sheets API: it answers the first len(fail)
+// calls with the given status codes and every call after that with ok.
+type fakeSheets struct {
+	calls      atomic.Int32
+	fail       []int
+	retryAfter string
+	ok         interface{}
+}
+
+func (f *fakeSheets) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := int(f.calls.Add(1)) - 1
+	w.Header().Set("Content-Type", "application/json")
+	if n < len(f.fail) {
+		if f.retryAfter != "" {
+			w.Header().Set("Retry-After", f.retryAfter)
+		}
+		w.WriteHeader(f.fail[n])
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{"code": f.fail[n], "message": "fake failure"},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(f.ok)
+}
+
+func newTestClient(t *testing.T, fake *fakeSheets, policy RetryPolicy) (*Client, *[]time.Duration) {
+	t.Helper()
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	c, err := newClient(context.Background(), "sheet-id", policy, nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+	c.tableIDs["ES"] = "table-es"
+
+	var slept []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return c, &slept
+}
+
+func testPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, BaseDelay: 10 * time.Millisecond, MaxDelay: time.Second}
+}
+
+func TestFetchRows_RetriesQuotaErrorHonouringRetryAfter(t *testing.T) {
+	fake := &fakeSheets{
+		fail:       []int{http.StatusTooManyRequests},
+		retryAfter: "2",
+		ok:         map[string]interface{}{"values": [][]interface{}{{"a", "b"}}},
+	}
+	c, slept := newTestClient(t, fake, testPolicy())
+
+	rows, err := c.FetchRows(context.Background(), "ES")
+	if err != nil {
+		t.Fatalf("FetchRows() error: %v", err)
+	}
+	if len(rows) != 1 || fake.calls.Load() != 2 {
+		t.Errorf("expected 1 row after 2 calls, got %v after %d", rows, fake.calls.Load())
+	}
+	if len(*slept) != 1 || (*slept)[0] != 2*time.Second {
+		t.Errorf("expected a single 2s wait from Retry-After, got %v", *slept)
+	}
+}
+
+func TestWriteCells_GivesUpAfterMaxAttempts(t *testing.T) {
+	fake := &fakeSheets{fail: []int{503, 503, 503, 503}}
+	c, slept := newTestClient(t, fake, testPolicy())
+
+	err := c.WriteCells(context.Background(), "ES", []models.CellUpdate{{Row: 1, Col: 9, Value: "p-1"}})
+	if err == nil {
+		t.Fatal("expected error after exhausting retries")
+	}
+	if fake.calls.Load() != 3 || len(*slept) != 2 {
+		t.Errorf("expected 3 calls and 2 waits, got %d calls and %v", fake.calls.Load(), *slept)
+	}
+	for i, d := range *slept {
+		if d < 0 || d >= testPolicy().BaseDelay<<i {
+			t.Errorf("wait %d = %v outside the jittered backoff window", i, d)
+		}
+	}
+}
+
+func TestAppendRows_DoesNotRetryServerErrors(t *testing.T) {
+	fake := &fakeSheets{fail: []int{http.StatusInternalServerError}, ok: map[string]interface{}{}}
+	c, _ := newTestClient(t, fake, testPolicy())
+
+	err := c.AppendRows(context.Background(), "ES", [][]interface{}{{"a"}})
+	if err == nil || !strings.Contains(err.Error(), "append") {
+		t.Fatalf("expected append error, got %v", err)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("a 5xx append may have been applied; expected no retry, got %d calls", fake.calls.Load())
+	}
+}
+
+func TestAppendRows_RetriesQuotaError(t *testing.T) {
+	fake := &fakeSheets{fail: []int{http.StatusTooManyRequests}, ok: map[string]interface{}{}}
+	c, _ := newTestClient(t, fake, testPolicy())
+
+	if err := c.AppendRows(context.Background(), "ES", [][]interface{}{{"a"}}); err != nil {
+		t.Fatalf("AppendRows() error: %v", err)
+	}
+	if fake.calls.Load() != 2 {
+		t.Errorf("expected 2 calls, got %d", fake.calls.Load())
+	}
+}
+
+func TestFetchRows_DoesNotRetryClientErrors(t *testing.T) {
+	fake := &fakeSheets{fail: []int{http.StatusBadRequest}}
+	c, _ := newTestClient(t, fake, testPolicy())
+
+	if _, err := c.FetchRows(context.Background(), "ES"); err == nil {
+		t.Fatal("expected error")
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("expected 1 call, got %d", fake.calls.Load())
+	}
+}
+
+func TestTokenBucket_WaitsWhenQuotaIsSpent(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	b := newTokenBucket(2)
+	b.last = now
+	b.now = func() time.Time { return now }
+	var slept []time.Duration
+	b.sleep = func(_ context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		now = now.Add(d)
+		return nil
+	}
+
+	b.take(ctx)
+	b.take(ctx)
+	if len(slept) != 0 {
+		t.Fatalf("expected the first 2 calls to pass, slept %v", slept)
+	}
+	b.take(ctx)
+	if len(slept) != 1 || slept[0] != 30*time.Second {
+		t.Errorf("expected a 30s wait at 2 requests/minute, got %v", slept)
+	}
+}
+
+func TestFetchRows_StopsRetryingWhenContextIsDone(t *testing.T) {
+	fake := &fakeSheets{fail: []int{503, 503, 503}}
+	c, _ := newTestClient(t, fake, testPolicy())
+	ctx, cancel := context.WithCancel(context.Background())
+	c.sleep = func(ctx context.Context, _ time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if _, err := c.FetchRows(ctx, "ES"); err == nil {
+		t.Fatal("expected error")
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("expected no retry after cancellation, got %d calls", fake.calls.Load())
+	}
+}
+
+func TestFetchRows_AttemptTimeout(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-block:
+		case <-r.Context().Done():
+		}
+	}))
+	t.Cleanup(func() { close(block); srv.Close() })
+
+	policy := RetryPolicy{MaxAttempts: 1, AttemptTimeout: 50 * time.Millisecond}
+	c, err := newClient(context.Background(), "sheet-id", policy, nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := c.FetchRows(context.Background(), "ES"); err == nil {
+		t.Fatal("expected deadline error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("attempt timeout not applied, call took %v", elapsed)
+	}
+}
+
+func TestFetchSheets_SingleBatchGet(t *testing.T) {
+	var query string
+	fake := &fakeSheets{ok: map[string]interface{}{"valueRanges": []map[string]interface{}{
+		{"range": "'DIF'!A1:J2", "values": [][]interface{}{{"h"}, {"dif"}}},
+		{"range": "'Entradas e Saídas'!A1:J1", "values": [][]interface{}{{"h"}}},
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := newClient(context.Background(), "sheet-id", testPolicy(), nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+
+	got, err := c.FetchSheets(context.Background(), "DIF", "Entradas e Saídas")
+	if err != nil {
+		t.Fatalf("FetchSheets() error: %v", err)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("expected a single BatchGet, got %d calls", fake.calls.Load())
+	}
+	if len(got["DIF"]) != 2 || len(got["Entradas e Saídas"]) != 1 {
+		t.Errorf("ranges mapped to the wrong sheets: %v", got)
+	}
+	if strings.Count(query, "ranges=") != 2 {
+		t.Errorf("expected both ranges in the query, got %q", query)
+	}
+	values, _ := url.ParseQuery(query)
+	if got := values["ranges"]; len(got) != 2 || got[0] != "'DIF'" || got[1] != "'Entradas e Saídas'" {
+		t.Errorf("expected whole sheets, not a column span, got %q", got)
+	}
+}
+
+func TestUserEnteredValue_WritesMoneyAsNumber(t *testing.T) {
+	ev := userEnteredValue(models.Money(-123456))
+	if ev.NumberValue == nil || *ev.NumberValue != -1234.56 || ev.StringValue != nil {
+		t.Errorf("expected NumberValue -1234.56, got %+v", ev)
+	}
+	if ev := userEnteredValue("Mercado"); ev.StringValue == nil || *ev.StringValue != "Mercado" {
+		t.Errorf("expected StringValue, got %+v", ev)
+	}
+	if ev := userEnteredValue(nil); ev.StringValue == nil || *ev.StringValue != "" {
+		t.Errorf("expected an empty StringValue for nil, got %+v", ev)
+	}
+	if ev := userEnteredValue(float64(46187)); ev.NumberValue == nil || *ev.NumberValue != 46187 {
+		t.Errorf("expected a serial date to stay a number, got %+v", ev)
+	}
+	if ev := userEnteredValue(true); ev.BoolValue == nil || !*ev.BoolValue {
+		t.Errorf("expected BoolValue, got %+v", ev)
+	}
+}
+
+func TestFetchSheets_RendersUnformattedValuesUnlessConfigured(t *testing.T) {
+	var query string
+	fake := &fakeSheets{ok: map[string]interface{}{"valueRanges": []map[string]interface{}{
+		{"range": "'DIF'!A1:J1", "values": [][]interface{}{{"h"}}},
+	}}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := newClient(context.Background(), "sheet-id", testPolicy(), nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+
+	if _, err := c.FetchSheets(context.Background(), "DIF"); err != nil {
+		t.Fatalf("FetchSheets() error: %v", err)
+	}
+	if !strings.Contains(query, "valueRenderOption=UNFORMATTED_VALUE") || !strings.Contains(query, "dateTimeRenderOption=SERIAL_NUMBER") {
+		t.Errorf("expected unformatted values with serial dates, got %q", query)
+	}
+
+	c.UseFormattedValues(true)
+	if _, err := c.FetchSheets(context.Background(), "DIF"); err != nil {
+		t.Fatalf("FetchSheets() error: %v", err)
+	}
+	if !strings.Contains(query, "valueRenderOption=FORMATTED_VALUE") {
+		t.Errorf("expected formatted values, got %q", query)
+	}
+}
+
+func TestRawValue_WritesDatesAsSerials(t *testing.T) {
+	d := models.Date{Raw: "2026-06-14", Time: models.DateFromSerial(46187)}
+	if got := rawValue(d); got != float64(46187) {
+		t.Errorf("rawValue(date) = %v, want 46187", got)
+	}
+	if got := rawValue(models.Date{Raw: "ontem"}); got != "ontem" {
+		t.Errorf("rawValue(invalid date) = %v, want the raw text", got)
+	}
+	if got := rawValue("Casa"); got != "Casa" {
+		t.Errorf("rawValue(string) = %v", got)
+	}
+}
+
+func TestDeleteRow_RefusesRowsOutsideTheTable(t *testing.T) {
+	// The table spans rows 0 (header) to 4: the cached range is stale, the sheet has grown.
+	fake := &fakeSheets{ok: map[string]interface{}{"sheets": []map[string]interface{}{{
+		"properties": map[string]interface{}{"title": "ES"},
+		"tables": []map[string]interface{}{{"tableId": "table-es",
+			"range": map[string]interface{}{"sheetId": 1, "startRowIndex": 0, "endRowIndex": 5}}},
+	}}}}
+	c, _ := newTestClient(t, fake, testPolicy())
+	c.tableRanges["ES"] = &sheets.GridRange{SheetId: 1, StartRowIndex: 0, EndRowIndex: 3}
+	ctx := context.Background()
+
+	if err := c.DeleteRow(ctx, "ES", 0); err == nil {
+		t.Error("expected the header row to be refused")
+	}
+	if fake.calls.Load() != 0 {
+		t.Errorf("expected no call for the header row, got %d", fake.calls.Load())
+	}
+	if err := c.DeleteRow(ctx, "ES", 5); err == nil || !strings.Contains(err.Error(), "not a data row") {
+		t.Errorf("expected a row past the table to be refused, got %v", err)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("expected only the metadata refresh, got %d calls", fake.calls.Load())
+	}
+	if err := c.DeleteRow(ctx, "ES", 4); err != nil {
+		t.Errorf("DeleteRow() of a row within the refreshed range: %v", err)
+	}
+}
+
+func TestDeleteRows_SingleBatchUpdateBottomUp(t *testing.T) {
+	var body sheets.BatchUpdateSpreadsheetRequest
+	fake := &fakeSheets{ok: map[string]interface{}{}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		fake.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	c, err := newClient(context.Background(), "sheet-id", testPolicy(), nil,
+		option.WithEndpoint(srv.URL+"/"), option.WithHTTPClient(srv.Client()))
+	if err != nil {
+		t.Fatalf("newClient() error: %v", err)
+	}
+	c.tableIDs["REJ"] = "table-rej"
+	c.tableRanges["REJ"] = &sheets.GridRange{SheetId: 2, StartRowIndex: 0, EndRowIndex: 10}
+
+	if err := c.DeleteRows(context.Background(), "REJ", []int{2, 7, 4, 7}); err != nil {
+		t.Fatalf("DeleteRows() error: %v", err)
+	}
+	if fake.calls.Load() != 1 {
+		t.Errorf("expected a single BatchUpdate, got %d calls", fake.calls.Load())
+	}
+	var got []int64
+	for _, r := range body.Requests {
+		got = append(got, r.DeleteRange.Range.StartRowIndex)
+	}
+	if len(got) != 3 || got[0] != 7 || got[1] != 4 || got[2] != 2 {
+		t.Errorf("expected DeleteRange for rows 7, 4, 2 in that order, got %v", got)
+	}
+}
+
+func TestDeleteRows_ShrinksTheCachedRange(t *testing.T) {
+	// After the delete the table spans rows 0 to 2.
+	fake := &fakeSheets{ok: map[string]interface{}{"sheets": []map[string]interface{}{{
+		"properties": map[string]interface{}{"title": "ES"},
+		"tables": []map[string]interface{}{{"tableId": "table-es",
+			"range": map[string]interface{}{"sheetId": 1, "startRowIndex": 0, "endRowIndex": 3}}},
+	}}}}
+	c, _ := newTestClient(t, fake, testPolicy())
+	c.tableRanges["ES"] = &sheets.GridRange{SheetId: 1, StartRowIndex: 0, EndRowIndex: 5}
+	ctx := context.Background()
+
+	if err := c.DeleteRows(ctx, "ES", []int{3, 4}); err != nil {
+		t.Fatalf("DeleteRows() error: %v", err)
+	}
+	if end := c.tableRanges["ES"].EndRowIndex; end != 3 {
+		t.Errorf("expected the cached table to end at row 3, got %d", end)
+	}
+	// Row 4 is gone: it is checked against the sheet again and refused, not deleted.
+	if err := c.DeleteRow(ctx, "ES", 4); err == nil || !strings.Contains(err.Error(), "not a data row") {
+		t.Errorf("expected a deleted row to be refused, got %v", err)
+	}
+	if fake.calls.Load() != 2 {
+		t.Errorf("expected the delete and one metadata refresh, got %d calls", fake.calls.Load())
+	}
+}
+
+func TestDeleteRows_FailureDropsTheCachedRange(t *testing.T) {
+	fake := &fakeSheets{fail: []int{http.StatusBadRequest}, ok: map[string]interface{}{}}
+	c, _ := newTestClient(t, fake, testPolicy())
+	c.tableRanges["ES"] = &sheets.GridRange{SheetId: 1, StartRowIndex: 0, EndRowIndex: 5}
+
+	if err := c.DeleteRows(context.Background(), "ES", []int{2}); err == nil {
+		t.Fatal("expected the delete to fail")
+	}
+	if rng, ok := c.tableRanges["ES"]; ok {
+		t.Errorf("expected the cached range to be dropped, got %+v", rng)
+	}
+}
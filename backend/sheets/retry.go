@@ -0,0 +1,168 @@
+package sheets
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/api/googleapi"
+)
+
+// RetryPolicy controls how the client retries failed Sheets API calls.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of tries per call, the first included. 1 disables retries.
+	MaxAttempts int
+	// BaseDelay and MaxDelay bound the exponential backoff: attempt n waits a random
+	// duration in [0, min(MaxDelay, BaseDelay*2^n)).
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RequestsPerMinute sizes the token bucket shared by every call of the client.
+	// The Sheets API default quota is 60 requests per minute per user. 0 disables it.
+	RequestsPerMinute int
+	// AttemptTimeout is the deadline of each try, on top of the caller's context.
+	// 0 leaves only the caller's deadline.
+	AttemptTimeout time.Duration
+}
+
+// DefaultRetryPolicy matches the default Sheets API per-user quota.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:       5,
+		BaseDelay:         500 * time.Millisecond,
+		MaxDelay:          30 * time.Second,
+		RequestsPerMinute: 60,
+		AttemptTimeout:    20 * time.Second,
+	}
+}
+
+// retryClass tells which failures a call may be retried on.
+type retryClass int
+
+const (
+	// idempotent calls (reads, value updates) are retried on quota and server errors.
+	idempotent retryClass = iota
+	// nonIdempotent calls (appends, row deletions) are only retried on 429: a 5xx may have
+	// been applied already, and repeating it would duplicate or delete another row.
+	nonIdempotent
+)
+
+// retryable reports whether err is worth another try and the delay the server asked for
+// through Retry-After, if any.
+func retryable(err error, class retryClass) (bool, time.Duration) {
+	var gerr *googleapi.Error
+	if errors.As(err, &gerr) {
+		wait := retryAfter(gerr.Header)
+		switch gerr.Code {
+		case http.StatusTooManyRequests:
+			return true, wait
+		case http.StatusInternalServerError, http.StatusBadGateway,
+			http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return class == idempotent, wait
+		}
+		return false, 0
+	}
+
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return class == idempotent, 0
+	}
+	return false, 0
+}
+
+// retryAfter reads the Retry-After header, given either in seconds or as an HTTP date.
+func retryAfter(h http.Header) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// backoff is the full-jitter delay before retry number attempt (0-based).
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.MaxDelay
+	if shift := p.BaseDelay << attempt; attempt < 32 && shift > 0 && shift < ceiling {
+		ceiling = shift
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)))
+}
+
+// tokenBucket spaces calls so the client stays under the per-minute quota instead of
+// finding it through 429s. Capacity equals the quota, refilled continuously.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perSec   float64
+	last     time.Time
+	now      func() time.Time
+	sleep    func(context.Context, time.Duration) error
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		capacity: float64(perMinute),
+		tokens:   float64(perMinute),
+		perSec:   float64(perMinute) / 60,
+		last:     time.Now(),
+		now:      time.Now,
+		sleep:    sleepCtx,
+	}
+}
+
+// take blocks until a token is available or ctx is done. A nil bucket never blocks.
+func (b *tokenBucket) take(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		now := b.now()
+		b.tokens += now.Sub(b.last).Seconds() * b.perSec
+		if b.tokens > b.capacity {
+			b.tokens = b.capacity
+		}
+		b.last = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.perSec * float64(time.Second))
+		b.mu.Unlock()
+		if err := b.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepCtx waits for d, returning early with ctx's error if it is done first.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,437 @@
+// Package sqlstore implements the service's SheetRepository over a SQLite database, with
+// ES, HOM, REJ and the audit trail as tables and DIF as a view computed from them.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"olivia-conciliation/backend/models"
+
+	_ "modernc.org/sqlite"
+)
+
+// Tabs maps the configured sheet names (SHEET_ES, SHEET_DIF...) to the store's tables, so
+// the service keeps addressing tabs by the same names it uses against the spreadsheet.
+type Tabs struct {
+	ES  string
+	DIF string
+	REJ string
+	HOM string
+	AUD string // optional
+}
+
+// transactionColumns are the spreadsheet columns A:J, in order.
+var transactionColumns = []string{
+	"id", "data", "descricao", "valor", "categoria", "dono", "banco", "conta", "recorrente", "id_parcela",
+}
+
+// auditColumns are the columns of the audit tab, in the order service.audit writes them.
+var auditColumns = []string{"timestamp", "action", "id_parcela", "sheet", "row", "before", "after"}
+
+type table struct {
+	name     string
+	columns  []string
+	readOnly bool
+}
+
+var (
+	tableES  = table{name: "es", columns: transactionColumns}
+	tableHOM = table{name: "hom", columns: transactionColumns}
+	tableREJ = table{name: "rej", columns: transactionColumns}
+	tableAUD = table{name: "aud", columns: auditColumns}
+	// DIF is a view and shares the header of HOM, the tab it filters.
+	tableDIF = table{name: "dif", columns: transactionColumns, readOnly: true}
+)
+
+// schema is applied on every Open; every statement is idempotent.
+//
+// Rows keep the spreadsheet order through row_id: the row index the service sees is the
+// position in row_id order, header included, so deleting a row shifts the ones below it
+// as in the spreadsheet.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS headers (tab TEXT PRIMARY KEY, cells TEXT NOT NULL)`,
+	createTable(tableES),
+	createTable(tableHOM),
+	createTable(tableREJ),
+	createTable(tableAUD),
+	`CREATE INDEX IF NOT EXISTS es_id_parcela ON es (id_parcela)`,
+	`CREATE INDEX IF NOT EXISTS rej_id_parcela ON rej (id_parcela)`,
+	`CREATE INDEX IF NOT EXISTS hom_id_parcela ON hom (id_parcela)`,
+	// The spreadsheet formula: the HOM rows with an IdParcela that is in neither ES nor
+	// REJ. Dropped and recreated so databases created with an older definition pick it up.
+	`DROP VIEW IF EXISTS dif`,
+	`CREATE VIEW dif AS
+		SELECT * FROM hom
+		WHERE trim(id_parcela) <> ''
+		  AND trim(id_parcela) NOT IN (
+				SELECT trim(id_parcela) FROM es WHERE trim(id_parcela) <> ''
+				UNION
+				SELECT trim(id_parcela) FROM rej WHERE trim(id_parcela) <> '')`,
+}
+
+func createTable(t table) string {
+	cols := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		cols[i] = c + ` TEXT NOT NULL DEFAULT ''`
+	}
+	return fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (row_id INTEGER PRIMARY KEY AUTOINCREMENT, %s)`,
+		t.name, strings.Join(cols, ", "))
+}
+
+// Store keeps each tab in its own table, in the default layout (A:J, IdParcela in J), which
+// the DIF view relies on; cells past J are dropped. migrate-sqlite moves a spreadsheet with
+// another layout onto this one by header. Cells are read back as strings, like the
+// formatted values the Sheets API returns.
+type Store struct {
+	db   *sql.DB
+	tabs map[string]table
+}
+
+// Open opens (or creates) the database at path and applies the schema.
+func Open(ctx context.Context, path string, tabs Tabs) (*Store, error) {
+	q := url.Values{}
+	q.Add("_pragma", "busy_timeout(5000)")
+	q.Add("_pragma", "journal_mode(WAL)")
+	db, err := sql.Open("sqlite", "file:"+path+"?"+q.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("unable to open %s: %w", path, err)
+	}
+	// A single connection makes the pool serialize writers instead of having them fail
+	// with SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	for _, stmt := range schema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to apply schema to %s: %w", path, err)
+		}
+	}
+
+	s := &Store{db: db, tabs: make(map[string]table)}
+	for name, t := range map[string]table{tabs.ES: tableES, tabs.HOM: tableHOM, tabs.REJ: tableREJ, tabs.AUD: tableAUD, tabs.DIF: tableDIF} {
+		if name != "" {
+			s.tabs[name] = t
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+func (s *Store) table(sheetName string) (table, error) {
+	t, ok := s.tabs[sheetName]
+	if !ok {
+		return table{}, fmt.Errorf("sheet %q has no table in the SQLite store", sheetName)
+	}
+	return t, nil
+}
+
+// queryer is what reads need, satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (s *Store) FetchRows(ctx context.Context, sheetName string) ([][]interface{}, error) {
+	t, err := s.table(sheetName)
+	if err != nil {
+		return nil, err
+	}
+	return readTable(ctx, s.db, t)
+}
+
+// FetchSheets reads every tab inside one transaction, so the result is a consistent snapshot.
+func (s *Store) FetchSheets(ctx context.Context, sheetNames ...string) (map[string][][]interface{}, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	out := make(map[string][][]interface{}, len(sheetNames))
+	for _, name := range sheetNames {
+		t, err := s.table(name)
+		if err != nil {
+			return nil, err
+		}
+		if out[name], err = readTable(ctx, tx, t); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+func (s *Store) WriteCell(ctx context.Context, sheetName string, rowIndex, colIndex int, value interface{}) error {
+	return s.WriteCells(ctx, sheetName, []models.CellUpdate{{Row: rowIndex, Col: colIndex, Value: models.CellText(value)}})
+}
+
+// WriteCells applies every cell in one transaction.
+func (s *Store) WriteCells(ctx context.Context, sheetName string, cells []models.CellUpdate) error {
+	if len(cells) == 0 {
+		return nil
+	}
+	return s.write(ctx, sheetName, func(tx *sql.Tx, t table) error {
+		for _, cell := range cells {
+			if cell.Col < 0 || cell.Col >= len(t.columns) {
+				return fmt.Errorf("column %d is not stored for sheet %q", cell.Col, sheetName)
+			}
+			if cell.Row == 0 {
+				if err := writeHeaderCell(ctx, tx, t, cell.Col, cell.Value); err != nil {
+					return err
+				}
+				continue
+			}
+			rowID, err := rowIDAt(ctx, tx, t, cell.Row)
+			if err != nil {
+				return fmt.Errorf("unable to update sheet %q: %w", sheetName, err)
+			}
+			stmt := fmt.Sprintf(`UPDATE %s SET %s = ? WHERE row_id = ?`, t.name, t.columns[cell.Col])
+			if _, err := tx.ExecContext(ctx, stmt, cell.Value, rowID); err != nil {
+				return fmt.Errorf("unable to update sheet %q: %w", sheetName, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *Store) AppendRow(ctx context.Context, sheetName string, values []interface{}) error {
+	return s.AppendRows(ctx, sheetName, [][]interface{}{values})
+}
+
+// AppendRows inserts every row in one transaction.
+func (s *Store) AppendRows(ctx context.Context, sheetName string, rows [][]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return s.write(ctx, sheetName, func(tx *sql.Tx, t table) error {
+		return insertRows(ctx, tx, t, rows)
+	})
+}
+
+// DeleteRow removes row rowIndex (0-based, header included); the rows below shift up.
+func (s *Store) DeleteRow(ctx context.Context, sheetName string, rowIndex int) error {
+	return s.DeleteRows(ctx, sheetName, []int{rowIndex})
+}
+
+// DeleteRows removes every row in rowIndexes in one transaction. The row_ids are all
+// resolved before the first delete, so the indexes refer to the rows as they were.
+func (s *Store) DeleteRows(ctx context.Context, sheetName string, rowIndexes []int) error {
+	if len(rowIndexes) == 0 {
+		return nil
+	}
+	return s.write(ctx, sheetName, func(tx *sql.Tx, t table) error {
+		ids := make([]int64, 0, len(rowIndexes))
+		for _, rowIndex := range rowIndexes {
+			if rowIndex < 1 {
+				return fmt.Errorf("row %d is not a data row of sheet %q", rowIndex, sheetName)
+			}
+			rowID, err := rowIDAt(ctx, tx, t, rowIndex)
+			if err != nil {
+				return fmt.Errorf("unable to delete from sheet %q: %w", sheetName, err)
+			}
+			ids = append(ids, rowID)
+		}
+		for _, id := range ids {
+			if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s WHERE row_id = ?`, t.name), id); err != nil {
+				return fmt.Errorf("unable to delete from sheet %q: %w", sheetName, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Replace swaps the whole content of each tab, header included, in a single transaction.
+// It is what migrate-sqlite uses to import the spreadsheet.
+func (s *Store) Replace(ctx context.Context, tabs map[string][][]interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, rows := range tabs {
+		t, err := s.table(name)
+		if err != nil {
+			return err
+		}
+		if t.readOnly {
+			return fmt.Errorf("sheet %q is a view and cannot be imported", name)
+		}
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s`, t.name)); err != nil {
+			return fmt.Errorf("unable to clear sheet %q: %w", name, err)
+		}
+		var header []interface{}
+		if len(rows) > 0 {
+			header, rows = rows[0], rows[1:]
+		}
+		if err := writeHeader(ctx, tx, t, header); err != nil {
+			return err
+		}
+		if err := insertRows(ctx, tx, t, rows); err != nil {
+			return fmt.Errorf("unable to import sheet %q: %w", name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// write runs fn in a transaction, committed only if it succeeds.
+func (s *Store) write(ctx context.Context, sheetName string, fn func(*sql.Tx, table) error) error {
+	t, err := s.table(sheetName)
+	if err != nil {
+		return err
+	}
+	if t.readOnly {
+		return fmt.Errorf("sheet %q is computed from the other tabs and cannot be written", sheetName)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(tx, t); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func readTable(ctx context.Context, q queryer, t table) ([][]interface{}, error) {
+	header, err := readHeader(ctx, q, t)
+	if err != nil {
+		return nil, err
+	}
+	out := [][]interface{}{header}
+
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`SELECT %s FROM %s ORDER BY row_id`, strings.Join(t.columns, ", "), t.name))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read table %s: %w", t.name, err)
+	}
+	defer rows.Close()
+
+	cells := make([]string, len(t.columns))
+	dest := make([]interface{}, len(cells))
+	for i := range cells {
+		dest[i] = &cells[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("unable to read table %s: %w", t.name, err)
+		}
+		out = append(out, trimRow(cells))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read table %s: %w", t.name, err)
+	}
+	return out, nil
+}
+
+// headerTab is the key of a table's header: DIF shows the header of HOM.
+func headerTab(t table) string {
+	if t.name == tableDIF.name {
+		return tableHOM.name
+	}
+	return t.name
+}
+
+func readHeader(ctx context.Context, q queryer, t table) ([]interface{}, error) {
+	var raw string
+	err := q.QueryRowContext(ctx, `SELECT cells FROM headers WHERE tab = ?`, headerTab(t)).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []interface{}{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read header of %s: %w", t.name, err)
+	}
+	var header []interface{}
+	if err := json.Unmarshal([]byte(raw), &header); err != nil {
+		return nil, fmt.Errorf("unable to read header of %s: %w", t.name, err)
+	}
+	return header, nil
+}
+
+func writeHeader(ctx context.Context, tx *sql.Tx, t table, header []interface{}) error {
+	cells := make([]string, 0, len(header))
+	for _, v := range header {
+		cells = append(cells, cellString(v))
+	}
+	raw, err := json.Marshal(trimRow(cells))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO headers (tab, cells) VALUES (?, ?)
+		ON CONFLICT (tab) DO UPDATE SET cells = excluded.cells`, t.name, string(raw))
+	if err != nil {
+		return fmt.Errorf("unable to write header of %s: %w", t.name, err)
+	}
+	return nil
+}
+
+func writeHeaderCell(ctx context.Context, tx *sql.Tx, t table, col int, value string) error {
+	header, err := readHeader(ctx, tx, t)
+	if err != nil {
+		return err
+	}
+	for len(header) <= col {
+		header = append(header, "")
+	}
+	header[col] = value
+	return writeHeader(ctx, tx, t, header)
+}
+
+// rowIDAt finds the row_id of data row rowIndex (1-based, as the header is row 0).
+func rowIDAt(ctx context.Context, q queryer, t table, rowIndex int) (int64, error) {
+	var id int64
+	err := q.QueryRowContext(ctx, fmt.Sprintf(`SELECT row_id FROM %s ORDER BY row_id LIMIT 1 OFFSET ?`, t.name), rowIndex-1).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("row %d does not exist", rowIndex)
+	}
+	return id, err
+}
+
+func insertRows(ctx context.Context, tx *sql.Tx, t table, rows [][]interface{}) error {
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(t.columns)), ", ")
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(`INSERT INTO %s (%s) VALUES (%s)`,
+		t.name, strings.Join(t.columns, ", "), placeholders))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	args := make([]interface{}, len(t.columns))
+	for _, row := range rows {
+		for i := range args {
+			args[i] = ""
+			if i < len(row) {
+				args[i] = cellString(row[i])
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return fmt.Errorf("unable to insert into %s: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// trimRow drops trailing empty cells, matching what the Sheets API returns for a row.
+func trimRow(cells []string) []interface{} {
+	last := len(cells) - 1
+	for last >= 0 && cells[last] == "" {
+		last--
+	}
+	out := make([]interface{}, last+1)
+	for i := range out {
+		out[i] = cells[i]
+	}
+	return out
+}
+
+func cellString(v interface{}) string {
+	return models.CellText(v)
+}
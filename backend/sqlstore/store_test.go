@@ -0,0 +1,189 @@
+package sqlstore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"olivia-conciliation/backend/config"
+	"olivia-conciliation/backend/models"
+	"olivia-conciliation/backend/service"
+)
+
+var testTabs = Tabs{ES: "ES", DIF: "DIF", REJ: "REJ", HOM: "HOM", AUD: "AUD"}
+
+func row(descricao, valor, recorrente, idParcela string) []interface{} {
+	r := make([]interface{}, 10)
+	r[models.ColumnData] = "08/03/2026"
+	r[models.ColumnDescricao] = descricao
+	r[models.ColumnValor] = valor
+	r[models.ColumnDono] = "Fulano"
+	r[models.ColumnBanco] = "Nubank"
+	r[models.ColumnConta] = "Cartão"
+	r[models.ColumnRecorrente] = recorrente
+	r[models.ColumnIdParcela] = idParcela
+	return r
+}
+
+var header = []interface{}{"Id", "Data", "Descrição", "Valor", "Categoria", "Dono", "Banco", "Conta", "Recorrente", "IdParcela"}
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	ctx := context.Background()
+	s, err := Open(ctx, filepath.Join(t.TempDir(), "olivia.db"), testTabs)
+	if err != nil {
+		t.Fatalf("Open() error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	err = s.Replace(ctx, map[string][][]interface{}{
+		"HOM": {header,
+			row("LOJA MOVEIS PARC 03/10", "-450,00", "Sim", "p-moveis-03"),
+			row("PADARIA", "-23,50", "Não", "p-padaria"),
+			row("NETFLIX", "-55,90", "Não", "p-netflix"),
+			row("PIX", "120,00", "Não", "p-pix"),
+		},
+		"ES": {header,
+			row("Netflix", "-55,90", "Não", "p-netflix"),
+			row("Móveis 3/10", "-450,00", "Sim", ""),
+		},
+		"REJ": {header, row("PIX", "120,00", "Não", "p-pix")},
+		"AUD": {service.AuditHeader},
+	})
+	if err != nil {
+		t.Fatalf("Replace() error: %v", err)
+	}
+	return s
+}
+
+func difIds(t *testing.T, s *Store) []string {
+	t.Helper()
+	rows, err := s.FetchRows(context.Background(), "DIF")
+	if err != nil {
+		t.Fatalf("FetchRows(DIF) error: %v", err)
+	}
+	var ids []string
+	for _, r := range rows[1:] {
+		ids = append(ids, r[models.ColumnIdParcela].(string))
+	}
+	return ids
+}
+
+func TestStore_DIFViewFiltersESAndREJ(t *testing.T) {
+	s := newTestStore(t)
+
+	rows, err := s.FetchRows(context.Background(), "DIF")
+	if err != nil {
+		t.Fatalf("FetchRows() error: %v", err)
+	}
+	if rows[0][models.ColumnIdParcela] != "IdParcela" {
+		t.Errorf("expected the HOM header on the DIF, got %v", rows[0])
+	}
+	got := difIds(t, s)
+	if len(got) != 2 || got[0] != "p-moveis-03" || got[1] != "p-padaria" {
+		t.Errorf("expected [p-moveis-03 p-padaria], got %v", got)
+	}
+}
+
+func TestStore_WritesFollowRowIndices(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.WriteCells(ctx, "ES", []models.CellUpdate{{Row: 2, Col: models.ColumnIdParcela, Value: "p-moveis-03"}}); err != nil {
+		t.Fatalf("WriteCells() error: %v", err)
+	}
+	if err := s.DeleteRow(ctx, "REJ", 1); err != nil {
+		t.Fatalf("DeleteRow() error: %v", err)
+	}
+	if err := s.AppendRow(ctx, "REJ", row("PADARIA", "-23,50", "Não", "p-padaria")); err != nil {
+		t.Fatalf("AppendRow() error: %v", err)
+	}
+
+	got := difIds(t, s)
+	if len(got) != 1 || got[0] != "p-pix" {
+		t.Errorf("expected only p-pix left in the DIF, got %v", got)
+	}
+
+	// Apagar a linha 1 da ES desloca a antiga linha 2 para cima.
+	if err := s.DeleteRow(ctx, "ES", 1); err != nil {
+		t.Fatalf("DeleteRow() error: %v", err)
+	}
+	if err := s.WriteCell(ctx, "ES", 1, models.ColumnCategoria, "Casa"); err != nil {
+		t.Fatalf("WriteCell() error: %v", err)
+	}
+	es, _ := s.FetchRows(ctx, "ES")
+	if len(es) != 2 || es[1][models.ColumnDescricao] != "Móveis 3/10" || es[1][models.ColumnCategoria] != "Casa" {
+		t.Errorf("unexpected ES after delete and write: %v", es)
+	}
+}
+
+func TestStore_FailedBatchWritesNothing(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	err := s.WriteCells(ctx, "ES", []models.CellUpdate{
+		{Row: 1, Col: models.ColumnCategoria, Value: "Assinaturas"},
+		{Row: 9, Col: models.ColumnCategoria, Value: "Casa"},
+	})
+	if err == nil {
+		t.Fatal("expected an error writing a row that does not exist")
+	}
+	es, _ := s.FetchRows(ctx, "ES")
+	if len(es[1]) > models.ColumnCategoria && es[1][models.ColumnCategoria] != "" {
+		t.Errorf("expected the first cell rolled back, got %v", es[1])
+	}
+}
+
+func TestStore_DIFIsReadOnly(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.AppendRow(context.Background(), "DIF", row("X", "1", "Não", "p-x")); err == nil {
+		t.Error("expected an error writing to the DIF view")
+	}
+}
+
+func TestStore_ServesTheService(t *testing.T) {
+	s := newTestStore(t)
+	cfg := config.Config{SheetES: "ES", SheetDIF: "DIF", SheetREJ: "REJ", SheetHOM: "HOM", SheetAUD: "AUD",
+		MatchTolerance: config.ToleranceRule{Absolute: config.DefaultMatchTolerance}, MatchMaxGroupSize: 1}
+	logic := service.NewLogic(s, cfg)
+	ctx := context.Background()
+
+	if err := logic.Accept(ctx, 1, models.AcceptRequest{IdParcela: "p-moveis-03", EsRowIndices: []int{2}}); err != nil {
+		t.Fatalf("Accept() error: %v", err)
+	}
+	if got := difIds(t, s); len(got) != 1 || got[0] != "p-padaria" {
+		t.Errorf("expected the accepted row to leave the DIF, got %v", got)
+	}
+	aud, _ := s.FetchRows(ctx, "AUD")
+	if len(aud) != 2 || aud[1][1] != service.AuditActionAccept {
+		t.Errorf("expected the Accept in the audit table, got %v", aud)
+	}
+
+	if _, err := logic.Unlink(ctx, "p-moveis-03"); err != nil {
+		t.Fatalf("Unlink() error: %v", err)
+	}
+	if got := difIds(t, s); len(got) != 2 {
+		t.Errorf("expected the unlinked row back in the DIF, got %v", got)
+	}
+}
+
+func TestStore_DeleteRowsIsAllOrNothing(t *testing.T) {
+	s := newTestStore(t)
+	ctx := context.Background()
+
+	if err := s.DeleteRows(ctx, "HOM", []int{1, 9}); err == nil {
+		t.Fatal("expected an error for a row past the end")
+	}
+	if hom, _ := s.FetchRows(ctx, "HOM"); len(hom) != 5 {
+		t.Fatalf("expected nothing deleted after the error, got %d rows", len(hom))
+	}
+
+	// Os índices são os de antes de apagar: 1 e 3 são a LOJA MOVEIS e a NETFLIX.
+	if err := s.DeleteRows(ctx, "HOM", []int{3, 1}); err != nil {
+		t.Fatalf("DeleteRows() error: %v", err)
+	}
+	hom, _ := s.FetchRows(ctx, "HOM")
+	if len(hom) != 3 || hom[1][models.ColumnDescricao] != "PADARIA" || hom[2][models.ColumnDescricao] != "PIX" {
+		t.Errorf("unexpected HOM after DeleteRows: %v", hom)
+	}
+}
@@ -16,3 +16,13 @@ export const DEFAULT_EXECUTION_PAYLOAD = {
         csvEnabled: false
     }
 };
+
+export const ACCEPT_REASONS = {
+    not_found: 'não existe mais na ES',
+    duplicate: 'selecionada mais de uma vez',
+    not_pending: 'já foi conciliada',
+    other_account: 'é de outro Dono/Banco/Conta',
+    mismatch: 'fora da tolerância',
+    changed: 'mudou desde que foi listada',
+    unparseable: 'tem célula ilegível na planilha'
+};
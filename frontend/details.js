@@ -1,4 +1,4 @@
-import { API_URL } from './constants.js';
+import { API_URL, ACCEPT_REASONS } from './constants.js';
 
 export const detailsModule = {
     async loadDetails(id) {
@@ -39,6 +39,10 @@ export const detailsModule = {
                 <input type="checkbox" ${isSelected ? 'checked' : ''}>
                 <div class="candidate-details">
                     ${this.renderCardContent(c, true)}
+                    <div class="data-row">
+                        <span class="label">Pontuação</span>
+                        <span>${Math.round((c.score || 0) * 100)}%</span>
+                    </div>
                 </div>
             `;
             el.querySelector('input[type="checkbox"]').addEventListener('change', () => this.toggleCandidate(c.rowIndex));
@@ -55,7 +59,7 @@ export const detailsModule = {
             <div class="data-row">
                 <span class="label">Valor</span>
                 <span style="font-weight: bold; color: ${item.sheet === 'DIF' ? 'var(--secondary)' : 'var(--success)'}">
-                    R$ ${item.valor.toFixed(2)}
+                    R$ ${item.valor}
                 </span>
             </div>
             <div class="data-row">
@@ -93,19 +97,29 @@ export const detailsModule = {
             return;
         }
 
-        const difIndex = this.state.details.reference.rowIndex;
+        const ref = this.state.details.reference;
+        const difIndex = ref.rowIndex;
         const esIndices = Array.from(this.state.selectedCandidates);
+        // O que foi visto na tela: o backend recusa com 409 se as linhas mudaram desde então.
+        const expectedEsRows = (this.state.details.candidates || [])
+            .filter(c => this.state.selectedCandidates.has(c.rowIndex))
+            .map(c => ({ esRowIndex: c.rowIndex, idParcela: c.idParcela, descricao: c.descricao, valor: c.valor }));
 
         try {
             const res = await this.authorizedFetch(`${API_URL}/conciliations/${difIndex}/accept`, {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
-                body: JSON.stringify({ esRowIndices: esIndices })
+                body: JSON.stringify({ idParcela: ref.idParcela, esRowIndices: esIndices, expectedEsRows })
             });
 
             if (res.ok) {
                 this.showNotification('Conciliação realizada com sucesso!', 'success');
                 this.navigate('queue');
+            } else if ((res.headers.get('Content-Type') || '').includes('application/json')) {
+                const body = await res.json();
+                const rows = (body.rows || []).map(r => `linha ${r.esRowIndex}: ${ACCEPT_REASONS[r.reason] || r.detail}`);
+                const hint = res.status === 409 ? ' Recarregue a conciliação.' : '';
+                this.showNotification('Erro: ' + rows.join('; ') + '.' + hint, 'error');
             } else {
                 const txt = await res.text();
                 this.showNotification('Erro: ' + txt, 'error');
@@ -119,11 +133,13 @@ export const detailsModule = {
     async rejectCurrent() {
         if (!confirm('Tem certeza que deseja rejeitar esta conciliação? A referência será movida para REJ.')) return;
 
-        const difIndex = this.state.details.reference.rowIndex;
+        const ref = this.state.details.reference;
 
         try {
-            const res = await this.authorizedFetch(`${API_URL}/conciliations/${difIndex}/reject`, {
-                method: 'POST'
+            const res = await this.authorizedFetch(`${API_URL}/conciliations/${ref.rowIndex}/reject`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ idParcela: ref.idParcela })
             });
 
             if (res.ok) {
@@ -53,7 +53,7 @@ export const queueModule = {
                 </div>
                 <div class="item-value">
                     <div style="text-align: right; font-weight: bold; font-size: 1.2rem;">
-                        R$ ${item.valor.toFixed(2)}
+                        R$ ${item.valor}
                     </div>
                     <div class="candidates-count" style="text-align: right; font-size: 0.9rem;">
                         ${item.candidateCount} candidatas
@@ -93,7 +93,7 @@ export const queueModule = {
             const categoriaAtual = this.getCategoryDraft(item);
             const hasUnsavedCategory = categoriaAtual !== (item.categoria || '');
             const dataAtual = this.getDateDraft(item);
-            const hasUnsavedDate = dataAtual !== (item.data || '');
+            const hasUnsavedDate = dataAtual !== this.toIsoDate(item.data);
 
             row.innerHTML = `
                 <div class="non-recurring-main">
@@ -102,7 +102,7 @@ export const queueModule = {
                         <span>${this.escapeHtml(item.dono || '-')}</span>
                         <span>${this.escapeHtml(item.banco || '-')}</span>
                         <span>${this.escapeHtml(item.data || '-')}</span>
-                        <span>R$ ${(item.valor || 0).toFixed(2)}</span>
+                        <span>R$ ${item.valor}</span>
                     </div>
                 </div>
                 <div class="non-recurring-category">
@@ -150,7 +150,7 @@ export const queueModule = {
                 dateInput.addEventListener('input', (e) => {
                     const nextValue = e.target.value;
                     this.state.pendingDateEdits[item.difRowIndex] = nextValue;
-                    const changed = nextValue !== (item.data || '');
+                    const changed = nextValue !== this.toIsoDate(item.data);
                     if (dateUnsavedIndicator) dateUnsavedIndicator.classList.toggle('hidden', !changed);
                     if (saveDateButton) saveDateButton.disabled = !changed;
                 });
@@ -182,7 +182,13 @@ export const queueModule = {
     getDateDraft(item) {
         const draft = this.state.pendingDateEdits[item.difRowIndex];
         if (typeof draft === 'string') return draft;
-        return item.data || '';
+        return this.toIsoDate(item.data);
+    },
+
+    // A API devolve a Data como dd/mm/aaaa; o <input type="date"> só aceita aaaa-mm-dd.
+    toIsoDate(value) {
+        const m = /^(\d{2})\/(\d{2})\/(\d{4})$/.exec(value || '');
+        return m ? `${m[3]}-${m[2]}-${m[1]}` : (value || '');
     },
 
     // Edições de categoria/data são endereçadas pelo IdParcela (identidade estável da
@@ -254,8 +260,11 @@ export const queueModule = {
 
     async copyNonRecurringToES(difRowIndex) {
         try {
+            const item = this.findNonRecurringItem(difRowIndex);
             const res = await this.authorizedFetch(`${API_URL}/dif/non-recurring/${difRowIndex}/move-to-es`, {
-                method: 'POST'
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ idParcela: item?.idParcela || '' })
             });
             if (!res.ok) {
                 const txt = await res.text();
@@ -273,8 +282,11 @@ export const queueModule = {
         if (!confirm('Tem certeza que deseja rejeitar esta transação?')) return;
 
         try {
+            const item = this.findNonRecurringItem(difRowIndex);
             const res = await this.authorizedFetch(`${API_URL}/dif/non-recurring/${difRowIndex}/move-to-rej`, {
-                method: 'POST'
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ idParcela: item?.idParcela || '' })
             });
             if (!res.ok) {
                 const txt = await res.text();